@@ -0,0 +1,98 @@
+package core
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+type BackoffStrategy interface {
+	// attempt 從 1 起算；prev 為上一次的延遲，首次為 0
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+type BackoffFunc func(attempt int, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Next(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+type constantBackoff struct {
+	delay time.Duration
+}
+
+func ConstantBackoff(delay time.Duration) BackoffStrategy {
+	return constantBackoff{delay: delay}
+}
+
+func (b constantBackoff) Next(int, time.Duration) time.Duration {
+	return b.delay
+}
+
+type linearBackoff struct {
+	initial time.Duration
+	step    time.Duration
+	max     time.Duration
+}
+
+// * max 為 0 表示不設上限
+func LinearBackoff(initial, step, max time.Duration) BackoffStrategy {
+	return linearBackoff{initial: initial, step: step, max: max}
+}
+
+func (b linearBackoff) Next(attempt int, _ time.Duration) time.Duration {
+	return capBackoff(b.initial+b.step*time.Duration(attempt-1), b.max)
+}
+
+type exponentialBackoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+func ExponentialBackoff(initial, max time.Duration) BackoffStrategy {
+	return exponentialBackoff{initial: initial, max: max}
+}
+
+func (b exponentialBackoff) Next(attempt int, _ time.Duration) time.Duration {
+	delay := b.initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay <= 0 || (b.max > 0 && delay >= b.max) {
+			return capBackoff(b.max, b.max)
+		}
+	}
+	return capBackoff(delay, b.max)
+}
+
+type decorrelatedJitterBackoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// * AWS decorrelated jitter：sleep = min(max, rand(initial, prev * 3))
+func DecorrelatedJitterBackoff(initial, max time.Duration) BackoffStrategy {
+	return decorrelatedJitterBackoff{initial: initial, max: max}
+}
+
+func (b decorrelatedJitterBackoff) Next(_ int, prev time.Duration) time.Duration {
+	upper := max(prev*3, b.initial)
+	if upper <= b.initial {
+		return capBackoff(b.initial, b.max)
+	}
+	return capBackoff(b.initial+rand.N(upper-b.initial), b.max)
+}
+
+func capBackoff(delay, max time.Duration) time.Duration {
+	if delay < 0 {
+		return 0
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+func WithBackoff(strategy BackoffStrategy) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.backoff = strategy
+	}
+}
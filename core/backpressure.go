@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+)
+
+type spaceWaiter struct {
+	priority Priority
+	seq      uint64
+}
+
+func WithBlock() EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.block = true
+	}
+}
+
+func (q *Queue) EnqueueWait(ctx context.Context, presetName string, action func(ctx context.Context) error, options ...EnqueueOption) (string, error) {
+	options = append(options[:len(options):len(options)], WithBlock())
+	return q.Enqueue(ctx, presetName, action, options...)
+}
+
+func (p *pending) PushWait(ctx context.Context, t *task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.waitSpaceLocked(ctx, t); err != nil {
+		return err
+	}
+	p.insertLocked(t)
+	return nil
+}
+
+// * 依優先級與等待順序排隊，僅隊首可取得空位；返回時仍持有鎖，呼叫端需立即寫入
+func (p *pending) waitSpaceLocked(ctx context.Context, t *task) error {
+	p.waitSeq++
+	w := &spaceWaiter{
+		priority: t.priority,
+		seq:      p.waitSeq,
+	}
+	i := len(p.waiters)
+	for i > 0 && p.waiters[i-1].priority > w.priority {
+		i--
+	}
+	p.waiters = append(p.waiters, nil)
+	copy(p.waiters[i+1:], p.waiters[i:])
+	p.waiters[i] = w
+
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.space.Broadcast()
+	})
+	defer stop()
+
+	for {
+		p.refillLocked()
+		var err error
+		switch {
+		case queueState(p.state.Load()) == stateClosed:
+			err = ErrQueueClosed
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case p.waiters[0] == w && p.policy.Len()+p.delayed.Len() < p.size:
+		default:
+			p.space.Wait()
+			continue
+		}
+
+		p.removeWaiterLocked(w)
+		// * 讓下一位確認是否仍有空位
+		p.space.Broadcast()
+		return err
+	}
+}
+
+func (p *pending) removeWaiterLocked(w *spaceWaiter) {
+	for i, v := range p.waiters {
+		if v == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+}
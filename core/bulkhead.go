@@ -0,0 +1,88 @@
+package core
+
+import (
+	"log/slog"
+	"time"
+)
+
+// * 依序取出 Policy 中第一個可執行的任務，略過者放回
+func (p *pending) takeLocked() *task {
+	var skipped []*QueuedTask
+	var found *task
+	for p.policy.Len() > 0 {
+		item := p.policy.Pop()
+		if item == nil {
+			break
+		}
+		if p.eligibleLocked(item.task) {
+			found = item.task
+			delete(p.queued, found)
+			p.trackDeadlineLocked(found, -1)
+			break
+		}
+		skipped = append(skipped, item)
+	}
+	for _, item := range skipped {
+		p.policy.Push(item)
+	}
+	return found
+}
+
+func (p *pending) eligibleLocked(t *task) bool {
+	if t.held || !p.keyReadyLocked(t) {
+		return false
+	}
+
+	config := p.presets[t.preset]
+	if config.MaxConcurrency > 0 && p.active[t.preset] >= config.MaxConcurrency {
+		return false
+	}
+	if bucket, ok := p.limiters[t.preset]; ok && !bucket.ready(time.Now()) {
+		return false
+	}
+
+	// * 其他 preset 尚未用滿的保留名額不可被占用
+	reserved := 0
+	for name, c := range p.presets {
+		if name == t.preset || c.MinReserved <= 0 {
+			continue
+		}
+		reserved += max(c.MinReserved-p.active[name], 0)
+	}
+	return p.workers-p.busy-1 >= reserved
+}
+
+func (p *pending) acquireLocked(t *task) {
+	p.running[t.ID] = t
+	p.forgetMergeLocked(t)
+	if bucket, ok := p.limiters[t.preset]; ok {
+		bucket.tokens--
+	}
+	p.active[t.preset]++
+	p.busy++
+	p.setKeyRunningLocked(t, true)
+}
+
+func (p *pending) releaseLocked(t *task) {
+	if p.running[t.ID] == t {
+		delete(p.running, t.ID)
+	}
+	p.setKeyRunningLocked(t, false)
+	p.active[t.preset]--
+	if p.active[t.preset] <= 0 {
+		delete(p.active, t.preset)
+	}
+	p.busy--
+}
+
+func checkReserved(workers int, presets map[string]PresetConfig, logger *logger) {
+	reserved := 0
+	for _, c := range presets {
+		reserved += max(c.MinReserved, 0)
+	}
+	if reserved >= workers {
+		logger.log(slog.LevelWarn, "preset.reserved_exceeds_workers",
+			"reserved", reserved,
+			"workers", workers)
+	}
+}
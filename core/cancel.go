@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+type CancelResult int
+
+const (
+	CancelNotFound CancelResult = iota
+	CancelPending               // 從待處理佇列移除
+	CancelRunning               // 已取消執行中任務的 context
+)
+
+func (r CancelResult) String() string {
+	switch r {
+	case CancelPending:
+		return "pending"
+	case CancelRunning:
+		return "running"
+	default:
+		return "not_found"
+	}
+}
+
+func WithBoundContext() EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.bound = true
+	}
+}
+
+func (q *Queue) Cancel(id string) (CancelResult, error) {
+	result, task := q.pending.Cancel(id)
+
+	switch result {
+	case CancelPending:
+		q.journal.write(journalCancel, task)
+		q.finalize(task, StateCanceled, context.Canceled, 0)
+		q.logger.log(slog.LevelInfo, "task.canceled",
+			"id", task.ID,
+			"preset", task.preset,
+			"running", false,
+			task.labelAttr(),
+		)
+	case CancelRunning:
+		// * 由 execute 記錄 canceled 狀態
+		task.abort()
+	default:
+		return result, fmt.Errorf("task not found: %s", id)
+	}
+
+	return result, nil
+}
+
+// * enqueue ctx 取消時撤回任務；截止時間到期由 Pop 過期與執行逾時處理
+func (q *Queue) bind(ctx context.Context, task *task) {
+	if !task.bound {
+		return
+	}
+
+	id := task.ID
+	task.bind(context.AfterFunc(ctx, func() {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			q.Cancel(id)
+		}
+	}))
+}
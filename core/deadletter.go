@@ -0,0 +1,404 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type DeadLetter struct {
+	ID         string        `json:"id"`
+	Preset     string        `json:"preset,omitempty"`
+	Handler    string        `json:"handler,omitempty"`
+	Payload    []byte        `json:"payload,omitempty"`
+	State      TaskState     `json:"state"`
+	Attempts   int           `json:"attempts"`
+	Errors     []string      `json:"errors,omitempty"`
+	Timeout    time.Duration `json:"timeout,omitempty"`
+	RetryMax   int           `json:"retry_max,omitempty"`
+	EnqueuedAt time.Time     `json:"enqueued_at"`
+	FailedAt   time.Time     `json:"failed_at"`
+}
+
+type DeadLetterSink interface {
+	Put(entry DeadLetter) error
+	Get(id string) (DeadLetter, bool, error)
+	List() ([]DeadLetter, error)
+	Remove(id string) error
+	Purge() error
+}
+
+type memoryDeadLetter struct {
+	mu      sync.Mutex
+	order   []string
+	entries map[string]DeadLetter
+}
+
+func NewMemoryDeadLetter() DeadLetterSink {
+	return &memoryDeadLetter{
+		entries: make(map[string]DeadLetter),
+	}
+}
+
+func (m *memoryDeadLetter) Put(entry DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[entry.ID]; !ok {
+		m.order = append(m.order, entry.ID)
+	}
+	m.entries[entry.ID] = entry
+	return nil
+}
+
+func (m *memoryDeadLetter) Get(id string) (DeadLetter, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[id]
+	return entry, ok, nil
+}
+
+func (m *memoryDeadLetter) List() ([]DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]DeadLetter, 0, len(m.entries))
+	for _, id := range m.order {
+		list = append(list, m.entries[id])
+	}
+	return list, nil
+}
+
+func (m *memoryDeadLetter) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[id]; !ok {
+		return nil
+	}
+	delete(m.entries, id)
+	for i, v := range m.order {
+		if v == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memoryDeadLetter) Purge() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.order = nil
+	m.entries = make(map[string]DeadLetter)
+	return nil
+}
+
+type fileDeadLetterRecord struct {
+	Op    string      `json:"op"`
+	ID    string      `json:"id,omitempty"`
+	Entry *DeadLetter `json:"entry,omitempty"`
+}
+
+// * 僅附加寫入，Remove / Purge 以 tombstone 紀錄
+type fileDeadLetter struct {
+	memory  *memoryDeadLetter
+	mu      sync.Mutex
+	file    *os.File
+	path    string
+	corrupt []error
+}
+
+// * 由 New 注入 Queue 的 logger
+type deadLetterLogger interface {
+	setLogger(logger *logger)
+}
+
+func NewFileDeadLetter(path string) (DeadLetterSink, error) {
+	memory := NewMemoryDeadLetter().(*memoryDeadLetter)
+	var corrupt []error
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var r fileDeadLetterRecord
+			if jsonErr := json.Unmarshal(line, &r); jsonErr != nil {
+				corrupt = append(corrupt, jsonErr)
+			} else {
+				switch r.Op {
+				case "put":
+					if r.Entry != nil {
+						memory.Put(*r.Entry)
+					}
+				case "remove":
+					memory.Remove(r.ID)
+				case "purge":
+					memory.Purge()
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &fileDeadLetter{
+		memory:  memory,
+		file:    file,
+		path:    path,
+		corrupt: corrupt,
+	}, nil
+}
+
+// * 建立時尚無 Queue，損毀紀錄延後至接上 logger 時輸出
+func (f *fileDeadLetter) setLogger(logger *logger) {
+	f.mu.Lock()
+	corrupt := f.corrupt
+	f.corrupt = nil
+	f.mu.Unlock()
+
+	for _, err := range corrupt {
+		logger.log(slog.LevelWarn, "deadletter.corrupt_record", "path", f.path, "error", err)
+	}
+}
+
+func (f *fileDeadLetter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
+
+func (f *fileDeadLetter) append(r fileDeadLetterRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := f.file.Write(line); err != nil {
+		return err
+	}
+	// * 與 journal 一致逐筆落盤，避免已標記 fail 的任務在崩潰後消失
+	return f.file.Sync()
+}
+
+func (f *fileDeadLetter) Put(entry DeadLetter) error {
+	if err := f.append(fileDeadLetterRecord{Op: "put", Entry: &entry}); err != nil {
+		return err
+	}
+	return f.memory.Put(entry)
+}
+
+func (f *fileDeadLetter) Get(id string) (DeadLetter, bool, error) {
+	return f.memory.Get(id)
+}
+
+func (f *fileDeadLetter) List() ([]DeadLetter, error) {
+	return f.memory.List()
+}
+
+func (f *fileDeadLetter) Remove(id string) error {
+	if err := f.append(fileDeadLetterRecord{Op: "remove", ID: id}); err != nil {
+		return err
+	}
+	return f.memory.Remove(id)
+}
+
+func (f *fileDeadLetter) Purge() error {
+	if err := f.append(fileDeadLetterRecord{Op: "purge"}); err != nil {
+		return err
+	}
+	return f.memory.Purge()
+}
+
+func (q *Queue) deadLetter(task *task, state TaskState) {
+	if q.config.DeadLetter == nil {
+		return
+	}
+
+	var retryMax int
+	if task.retryOn {
+		retryMax = task.retryMax
+	}
+	entry := DeadLetter{
+		ID:         task.ID,
+		Preset:     task.preset,
+		Handler:    task.handler,
+		Payload:    task.payload,
+		State:      state,
+		Attempts:   task.retryTimes + 1,
+		Errors:     task.failures,
+		Timeout:    task.timeout,
+		RetryMax:   retryMax,
+		EnqueuedAt: task.enqueueAt,
+		FailedAt:   time.Now(),
+	}
+
+	if err := q.config.DeadLetter.Put(entry); err != nil {
+		q.logger.log(slog.LevelError, "deadletter.put_failed", "id", task.ID, "preset", task.preset, "error", err, task.labelAttr())
+		return
+	}
+
+	// * closure 無法序列化，保留 Requeue 所需的部分；超過 Retention 時捨棄最舊者
+	if task.handler == "" {
+		q.deadMu.Lock()
+		if _, ok := q.deadTasks[task.ID]; !ok {
+			q.deadOrder = append(q.deadOrder, task.ID)
+		}
+		q.deadTasks[task.ID] = deadTask{
+			action:      task.action,
+			callback:    task.callback,
+			retryIf:     task.retryIf,
+			orderingKey: task.orderingKey,
+			backoff:     task.backoff,
+		}
+		for len(q.deadOrder) > max(q.config.Retention, 0) {
+			delete(q.deadTasks, q.deadOrder[0])
+			q.deadOrder = q.deadOrder[1:]
+		}
+		q.deadMu.Unlock()
+	}
+}
+
+type deadTask struct {
+	action      func(ctx context.Context) error
+	callback    func(id string)
+	retryIf     func(err error, attempt int) bool
+	orderingKey string
+	backoff     BackoffStrategy
+}
+
+func (q *Queue) DeadLetters() ([]DeadLetter, error) {
+	if q.config.DeadLetter == nil {
+		return nil, nil
+	}
+	return q.config.DeadLetter.List()
+}
+
+func (q *Queue) Requeue(id string) error {
+	sink := q.config.DeadLetter
+	if sink == nil {
+		return fmt.Errorf("dead letter is not configured")
+	}
+
+	entry, ok, err := sink.Get(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("dead letter not found: %s", id)
+	}
+
+	q.deadMu.Lock()
+	original, kept := q.deadTasks[id]
+	q.deadMu.Unlock()
+
+	var action func(ctx context.Context) error
+	switch {
+	case kept:
+		action = original.action
+	case entry.Handler != "":
+		handler, ok := q.handlers.get(entry.Handler)
+		if !ok {
+			return fmt.Errorf("handler not found: %s", entry.Handler)
+		}
+		action = bindHandler(handler, entry.Payload)
+	default:
+		return fmt.Errorf("dead letter action is not available: %s", id)
+	}
+
+	options := []EnqueueOption{WithTaskID(entry.ID)}
+	if entry.Timeout > 0 {
+		options = append(options, WithTimeout(entry.Timeout))
+	}
+	if entry.RetryMax > 0 {
+		options = append(options, WithRetry(entry.RetryMax))
+	}
+	if kept {
+		options = append(options, WithCallback(original.callback), WithRetryIf(original.retryIf), WithOrderingKey(original.orderingKey))
+		if original.backoff != nil {
+			options = append(options, WithBackoff(original.backoff))
+		}
+	}
+
+	task := q.newTask(entry.Preset, action, options)
+	task.handler = entry.Handler
+	task.payload = entry.Payload
+
+	if _, err := q.push(context.Background(), task); err != nil {
+		return err
+	}
+
+	q.deadMu.Lock()
+	if _, ok := q.deadTasks[id]; ok {
+		delete(q.deadTasks, id)
+		q.deadOrder = slices.DeleteFunc(q.deadOrder, func(v string) bool {
+			return v == id
+		})
+	}
+	q.deadMu.Unlock()
+
+	if err := sink.Remove(id); err != nil {
+		q.logger.log(slog.LevelError, "deadletter.remove_failed", "id", id, "error", err)
+	}
+	return nil
+}
+
+func (q *Queue) Purge() error {
+	if q.config.DeadLetter == nil {
+		return nil
+	}
+
+	q.deadMu.Lock()
+	q.deadTasks = make(map[string]deadTask)
+	q.deadOrder = nil
+	q.deadMu.Unlock()
+
+	return q.config.DeadLetter.Purge()
+}
+
+// * 實作 io.Closer 的 sink 於 Shutdown 時關閉
+func (q *Queue) closeDeadLetter() {
+	closer, ok := q.config.DeadLetter.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		q.logger.log(slog.LevelError, "deadletter.close_failed", "error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"cmp"
+	"container/heap"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// * 多次設定時取最早的截止時間
+func WithDeadline(t time.Time) EnqueueOption {
+	return func(c *enqueueConfig) {
+		if c.deadline.IsZero() || t.Before(c.deadline) {
+			c.deadline = t
+		}
+	}
+}
+
+// * 於套用時計算，重複使用的選項與排程每次觸發各自起算
+func WithTTL(d time.Duration) EnqueueOption {
+	return func(c *enqueueConfig) {
+		WithDeadline(time.Now().Add(d))(c)
+	}
+}
+
+// * journal 尚未寫入的任務待 Publish 後再結束，避免 cancel 紀錄早於 enqueue
+func (t *task) expired(now time.Time) bool {
+	return !t.held && !t.deadline.IsZero() && !now.Before(t.deadline)
+}
+
+// * 計數待處理中具截止時間的任務，為零時 expireLocked 略過掃描
+func (p *pending) trackDeadlineLocked(t *task, delta int) {
+	if !t.deadline.IsZero() {
+		p.deadlines += delta
+	}
+}
+
+// * 延遲區重建 heap 而非逐一 Remove，避免走訪時索引位移
+func (p *pending) expireLocked(now time.Time) []*task {
+	if p.deadlines == 0 {
+		return nil
+	}
+	var expired []*task
+
+	var items []*QueuedTask
+	for t, item := range p.queued {
+		if t.expired(now) {
+			items = append(items, item)
+		}
+	}
+	// * map 走訪順序不固定，依進入順序結束
+	slices.SortFunc(items, func(a, b *QueuedTask) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	for _, item := range items {
+		p.leaveLocked(item.task)
+		expired = append(expired, item.task)
+	}
+
+	n := len(expired)
+	delayed := p.delayed[:0]
+	for _, t := range p.delayed {
+		if t.expired(now) {
+			expired = append(expired, t)
+			continue
+		}
+		delayed = append(delayed, t)
+	}
+	if len(expired) > n {
+		clear(p.delayed[len(delayed):])
+		p.delayed = delayed
+		heap.Init(&p.delayed)
+		p.scheduleLocked()
+	}
+
+	for _, t := range expired {
+		p.forgetKeyLocked(t)
+		p.forgetMergeLocked(t)
+		p.trackDeadlineLocked(t, -1)
+	}
+	if len(expired) > 0 {
+		p.refillLocked()
+		p.space.Broadcast()
+	}
+	return expired
+}
+
+func (q *Queue) expire(expired []*task) {
+	for _, task := range expired {
+		q.logger.log(slog.LevelWarn, "task.expired",
+			"id", task.ID,
+			"preset", task.preset,
+			"deadline", task.deadline,
+			task.labelAttr(),
+		)
+		q.journal.write(journalCancel, task)
+		q.finalize(task, StateExpired, ErrExpired, 0)
+	}
+}
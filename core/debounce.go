@@ -0,0 +1,186 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"time"
+)
+
+// * 相同 key 且仍在等待中的任務會被取代並重設計時，靜止 d 後僅執行最後一次
+func WithDebounce(key string, d time.Duration) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.debounceKey = key
+		c.runAt = time.Now().Add(d)
+	}
+}
+
+// * 相同 key 的任務尚未開始執行時，保留第一個並捨棄後續任務
+func WithCoalesce(key string) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.coalesceKey = key
+	}
+}
+
+// * 回傳實際占用位置的任務；合併時不佔用新的 Size
+// * admit 於任務確定 ID 且入隊前、在鎖內呼叫，回傳 true 時任務保留至 Publish 才可被取出
+func (p *pending) Merge(ctx context.Context, t *task, admit func(t *task) bool) (*task, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if queueState(p.state.Load()) == stateClosed {
+		return nil, ErrQueueClosed
+	}
+
+	reserved := false
+	for {
+		if existing := p.mergeTargetLocked(t); existing != nil {
+			return p.mergeLocked(t, existing, admit), nil
+		}
+		if reserved {
+			t.held = admit(t)
+			p.insertLocked(t)
+			return t, nil
+		}
+		if !t.block {
+			t.held = admit(t)
+			if err := p.pushLocked(t); err != nil {
+				t.held = false
+				return nil, err
+			}
+			return t, nil
+		}
+		// * 等待期間鎖會釋放，取得空位後需重新檢查可合併的任務
+		if err := p.waitSpaceLocked(ctx, t); err != nil {
+			return nil, err
+		}
+		reserved = true
+	}
+}
+
+func (p *pending) mergeTargetLocked(t *task) *task {
+	if t.debounceKey != "" {
+		if existing, ok := p.debounced[t.debounceKey]; ok {
+			return existing
+		}
+	}
+	if t.coalesceKey != "" {
+		if existing, ok := p.coalesced[t.coalesceKey]; ok {
+			return existing
+		}
+	}
+	return nil
+}
+
+func (p *pending) mergeLocked(t, existing *task, admit func(t *task) bool) *task {
+	if t.debounceKey == "" || p.debounced[t.debounceKey] != existing {
+		return existing
+	}
+
+	for i, v := range p.delayed {
+		if v == existing {
+			heap.Remove(&p.delayed, i)
+			p.trackDeadlineLocked(existing, -1)
+			break
+		}
+	}
+	p.replaceKeyLocked(existing, t)
+	p.forgetMergeLocked(existing)
+
+	t.ID = existing.ID
+	t.held = admit(t)
+	p.insertLocked(t)
+	return existing
+}
+
+// * journal 於鎖外寫入後才開放 worker 取出
+func (p *pending) Publish(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t.held = false
+	p.cond.Broadcast()
+}
+
+func (p *pending) registerMergeLocked(t *task) {
+	if t.retryTimes > 0 {
+		return
+	}
+	if t.debounceKey != "" && t.runAt.After(time.Now()) {
+		p.debounced[t.debounceKey] = t
+	}
+	if t.coalesceKey != "" {
+		p.coalesced[t.coalesceKey] = t
+	}
+}
+
+func (p *pending) forgetDebounceLocked(t *task) {
+	if t.debounceKey != "" && p.debounced[t.debounceKey] == t {
+		delete(p.debounced, t.debounceKey)
+	}
+}
+
+func (p *pending) forgetMergeLocked(t *task) {
+	p.forgetDebounceLocked(t)
+	if t.coalesceKey != "" && p.coalesced[t.coalesceKey] == t {
+		delete(p.coalesced, t.coalesceKey)
+	}
+}
+
+func (q *Queue) pushMerge(ctx context.Context, task *task) (string, error) {
+	originalID := task.ID
+
+	// * debounce 取代時 ID 於合併時才確定；鎖內僅建立紀錄，fsync 於鎖外完成後才開放取出
+	var record *journalRecord
+	info := newTaskInfo(task)
+	holder, err := q.pending.Merge(ctx, task, q.journalAdmit(&record))
+	if err != nil {
+		q.status.remove(originalID)
+		return "", fmt.Errorf("enqueue failed: %w", err)
+	}
+	if record != nil {
+		q.journal.writeRecord(*record)
+		q.pending.Publish(task)
+	}
+
+	switch {
+	case holder == task:
+		q.bind(ctx, task)
+		q.observe("enqueue", info, Observer.OnEnqueue)
+		return task.ID, nil
+
+	case task.ID == holder.ID:
+		// * debounce：新任務沿用既有 ID 取代等待中的任務
+		if originalID != task.ID {
+			q.status.remove(originalID)
+		}
+		q.status.enqueue(task)
+		holder.release()
+		if holder.onFinish != nil {
+			holder.onFinish(StateCanceled, context.Canceled)
+		}
+		q.bind(ctx, task)
+		info.ID = task.ID
+		q.observe("enqueue", info, Observer.OnEnqueue)
+		return task.ID, nil
+
+	default:
+		// * coalesce：捨棄新任務，回傳既有 ID
+		q.status.remove(originalID)
+		if task.onFinish != nil {
+			task.onFinish(StateCanceled, context.Canceled)
+		}
+		return holder.ID, nil
+	}
+}
+
+func (q *Queue) journalAdmit(record **journalRecord) func(t *task) bool {
+	return func(t *task) bool {
+		if q.journal == nil || t.handler == "" {
+			return false
+		}
+		r := newJournalRecord(journalEnqueue, t)
+		*record = &r
+		return true
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"time"
+)
+
+type DedupMode int
+
+const (
+	DedupOff            DedupMode = iota // 不檢查重複 ID
+	DedupReject                          // 回傳 ErrDuplicateTask
+	DedupReturnExisting                  // 不入隊，回傳既有 ID
+)
+
+const uniqueSweepSize = 1024
+
+// * 完成後於 d 內以相同 ID 入隊會被視為重複；未設定 Config.Dedup 時以 DedupReject 處理
+func WithUniqueFor(d time.Duration) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.uniqueFor = d
+	}
+}
+
+func withDedupReject() EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.dedupReject = true
+	}
+}
+
+func (q *Queue) dedupMode(t *task) DedupMode {
+	mode := q.config.Dedup
+	if mode == DedupOff && t.uniqueFor > 0 {
+		mode = DedupReject
+	}
+	if mode == DedupReturnExisting && t.dedupReject {
+		mode = DedupReject
+	}
+	return mode
+}
+
+// * 檢查與登記於同一把鎖內完成，避免併發入隊同 ID
+func (s *statusStore) admit(t *task, dedup bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dedup && s.duplicateLocked(t.ID, time.Now()) {
+		return false
+	}
+	s.enqueueLocked(t)
+	return true
+}
+
+func (s *statusStore) duplicateLocked(id string, now time.Time) bool {
+	if r, ok := s.records[id]; ok && !r.State.Finished() {
+		return true
+	}
+	if until, ok := s.unique[id]; ok {
+		if now.Before(until) {
+			return true
+		}
+		delete(s.unique, id)
+	}
+	return false
+}
+
+func (s *statusStore) lockUnique(id string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.unique) >= uniqueSweepSize {
+		for k, until := range s.unique {
+			if !now.Before(until) {
+				delete(s.unique, k)
+			}
+		}
+	}
+	s.unique[id] = now.Add(d)
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"container/heap"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type DelayedTask struct {
+	ID     string
+	Preset string
+	RunAt  time.Time
+}
+
+type delayHeap []*task
+
+func (h delayHeap) Len() int {
+	return len(h)
+}
+
+func (h delayHeap) Less(i, j int) bool {
+	return h[i].runAt.Before(h[j].runAt)
+}
+
+func (h delayHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *delayHeap) Push(x interface{}) {
+	*h = append(*h, x.(*task))
+}
+
+func (h *delayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return task
+}
+
+// * 將到期的延遲任務移入 Policy，startAt 以到期時間起算
+func (p *pending) dueLocked(now time.Time) int {
+	moved := 0
+	for p.delayed.Len() > 0 && !p.delayed[0].runAt.After(now) {
+		t := heap.Pop(&p.delayed).(*task)
+		p.forgetDebounceLocked(t)
+		t.startAt = t.runAt
+		p.enterLocked(t)
+		moved++
+	}
+	return moved
+}
+
+func (p *pending) scheduleLocked() {
+	if p.delayed.Len() == 0 {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		return
+	}
+
+	wait := time.Until(p.delayed[0].runAt)
+	if p.timer == nil {
+		p.timer = time.AfterFunc(wait, p.onTimer)
+		return
+	}
+	p.timer.Reset(wait)
+}
+
+func (p *pending) onTimer() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if queueState(p.state.Load()) == stateClosed {
+		return
+	}
+	if moved := p.dueLocked(time.Now()); moved > 0 {
+		p.cond.Broadcast()
+	}
+	p.scheduleLocked()
+}
+
+func (p *pending) Delayed() []DelayedTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := make([]DelayedTask, 0, p.delayed.Len())
+	for _, t := range p.delayed {
+		list = append(list, DelayedTask{
+			ID:     t.ID,
+			Preset: t.preset,
+			RunAt:  t.runAt,
+		})
+	}
+	slices.SortFunc(list, func(a, b DelayedTask) int {
+		return a.RunAt.Compare(b.RunAt)
+	})
+	return list
+}
+
+func (q *Queue) Delayed() []DelayedTask {
+	return q.pending.Delayed()
+}
+
+// * 具名任務不寫入 cancel 紀錄，重啟後由 journal 重播
+func (q *Queue) cancelDelayed(canceled ...*task) {
+	for _, task := range canceled {
+		q.logger.log(slog.LevelInfo, "task.canceled",
+			"id", task.ID,
+			"preset", task.preset,
+			"run_at", task.runAt,
+			task.labelAttr(),
+		)
+		q.finalize(task, StateCanceled, ErrQueueClosed, 0)
+	}
+}
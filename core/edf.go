@@ -0,0 +1,19 @@
+package core
+
+type SchedulerMode int
+
+const (
+	SchedulerPriority SchedulerMode = iota // 依 Priority 排序，等待過久可晉升
+	SchedulerEDF                           // 依截止時間排序，Priority 僅作為同時截止的排序依據
+)
+
+// * 有截止時間者一律先於無截止時間者，後者僅在前者清空後執行
+func lessEDF(a, b *QueuedTask) bool {
+	switch {
+	case a.Deadline.IsZero() != b.Deadline.IsZero():
+		return !a.Deadline.IsZero()
+	case !a.Deadline.Equal(b.Deadline):
+		return a.Deadline.Before(b.Deadline)
+	}
+	return lessPriority(a, b)
+}
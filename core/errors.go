@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTimeout = errors.New("task timeout")
+	ErrPanic   = errors.New("panic")
+
+	ErrDuplicateTask = errors.New("duplicate task id")
+	ErrEvicted       = errors.New("task evicted")
+	ErrExpired       = errors.New("task deadline exceeded")
+
+	ErrQueueFull      = errors.New("queue is full")
+	ErrQueueClosed    = errors.New("queue is closed")
+	ErrAlreadyStarted = errors.New("queue already started")
+	ErrNotStarted     = errors.New("queue not started")
+)
+
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s after %s", ErrTimeout, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+type PanicError struct {
+	Value any
+	Stack []byte // recover 當下的 goroutine stack
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrPanic, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// * Err 為觸發中止的 context 錯誤
+type ShutdownError struct {
+	Remaining int
+	Err       error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown timeout: %d tasks remaining", e.Remaining)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// * 包裹後的錯誤不會重試，直接進入 failed
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+type Future[T any] struct {
+	id      string
+	done    chan struct{}
+	mu      sync.Mutex
+	value   T
+	err     error
+	ended   bool
+	attempt int
+}
+
+// * 於最後一次嘗試結束後才 resolve，重試對呼叫端透明
+func Submit[T any](q *Queue, ctx context.Context, presetName string, action func(ctx context.Context) (T, error), options ...EnqueueOption) (*Future[T], error) {
+	f := &Future[T]{
+		done: make(chan struct{}),
+	}
+
+	wrapped := func(ctx context.Context) error {
+		attempt := f.begin()
+		value, err := action(ctx)
+		if err == nil {
+			f.set(attempt, value)
+		}
+		return err
+	}
+
+	// * 回傳既有 ID 會讓 Future 永遠無法 resolve，改為拒絕
+	options = append(options, withFinish(f.resolve), withDedupReject())
+	id, err := q.Enqueue(ctx, presetName, wrapped, options...)
+	if err != nil {
+		return nil, err
+	}
+	f.id = id
+
+	return f, nil
+}
+
+func (f *Future[T]) begin() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempt++
+	return f.attempt
+}
+
+// * 逾時後仍在執行的舊嘗試不可覆寫較新嘗試或已 resolve 的結果
+func (f *Future[T]) set(attempt int, value T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.ended && attempt == f.attempt {
+		f.value = value
+	}
+}
+
+func (f *Future[T]) resolve(state TaskState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ended {
+		return
+	}
+	f.ended = true
+	if state != StateSucceeded {
+		var zero T
+		f.value = zero
+		f.err = err
+	}
+	close(f.done)
+}
+
+func (f *Future[T]) ID() string {
+	return f.id
+}
+
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
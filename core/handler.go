@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type Handler func(ctx context.Context, payload []byte) error
+
+type handlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func newHandlerRegistry(handlers map[string]Handler) *handlerRegistry {
+	registry := &handlerRegistry{
+		handlers: make(map[string]Handler, len(handlers)),
+	}
+	for k, v := range handlers {
+		registry.handlers[k] = v
+	}
+	return registry
+}
+
+func (r *handlerRegistry) get(name string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+func (r *handlerRegistry) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.handlers))
+	for k := range r.handlers {
+		names = append(names, k)
+	}
+	return names
+}
+
+// * 需在 Start 前註冊，journal 重播才找得到對應 handler
+func (q *Queue) Register(name string, handler func(ctx context.Context, payload []byte) error) error {
+	if name == "" {
+		return fmt.Errorf("handler name is empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler is nil: %s", name)
+	}
+
+	q.handlers.mu.Lock()
+	defer q.handlers.mu.Unlock()
+
+	if _, ok := q.handlers.handlers[name]; ok {
+		return fmt.Errorf("handler already registered: %s", name)
+	}
+	q.handlers.handlers[name] = handler
+	return nil
+}
+
+func (q *Queue) Handlers() []string {
+	names := q.handlers.names()
+	slices.Sort(names)
+	return names
+}
+
+func (q *Queue) EnqueueNamed(ctx context.Context, presetName, handlerName string, payload []byte, options ...EnqueueOption) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	handler, ok := q.handlers.get(handlerName)
+	if !ok {
+		return "", fmt.Errorf("handler not found: %s", handlerName)
+	}
+
+	// * 複製 payload，避免呼叫端後續修改影響已入隊任務
+	payload = bytes.Clone(payload)
+	task := q.newTask(presetName, bindHandler(handler, payload), options)
+	task.handler = handlerName
+	task.payload = payload
+
+	return q.push(ctx, task)
+}
+
+func bindHandler(handler Handler, payload []byte) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return handler(ctx, payload)
+	}
+}
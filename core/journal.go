@@ -0,0 +1,314 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+type journalOp string
+
+const (
+	journalEnqueue  journalOp = "enqueue"
+	journalStart    journalOp = "start"
+	journalRetry    journalOp = "retry"
+	journalComplete journalOp = "complete"
+	journalFail     journalOp = "fail"
+	journalCancel   journalOp = "cancel"
+)
+
+type journalRecord struct {
+	Op          journalOp         `json:"op"`
+	ID          string            `json:"id"`
+	Preset      string            `json:"preset,omitempty"`
+	Handler     string            `json:"handler,omitempty"`
+	Payload     []byte            `json:"payload,omitempty"`
+	Timeout     time.Duration     `json:"timeout,omitempty"`
+	RetryOn     bool              `json:"retry_on,omitempty"`
+	RetryMax    int               `json:"retry_max,omitempty"`
+	RetryTimes  int               `json:"retry_times,omitempty"`
+	EnqueueAt   *time.Time        `json:"enqueue_at,omitempty"`
+	RunAt       *time.Time        `json:"run_at,omitempty"`
+	OrderingKey string            `json:"ordering_key,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Deadline    *time.Time        `json:"deadline,omitempty"`
+	Time        time.Time         `json:"time"`
+}
+
+type journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	records []journalRecord
+	logger  *logger
+}
+
+func openJournal(path string, logger *logger) (*journal, error) {
+	records, err := loadJournal(path, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// * 重寫為僅含未完成任務的 enqueue 紀錄，避免檔案無限成長
+	tmp := path + ".tmp"
+	var buf bytes.Buffer
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileSync(tmp, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{
+		file:    file,
+		records: records,
+		logger:  logger,
+	}, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func loadJournal(path string, logger *logger) ([]journalRecord, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var order []string
+	unfinished := make(map[string]*journalRecord)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var r journalRecord
+			if jsonErr := json.Unmarshal(line, &r); jsonErr != nil {
+				// * 崩潰時可能殘留未寫完的最後一行
+				logger.log(slog.LevelWarn, "journal.corrupt_record", "error", jsonErr)
+			} else {
+				switch r.Op {
+				case journalEnqueue:
+					if _, ok := unfinished[r.ID]; !ok {
+						order = append(order, r.ID)
+					}
+					record := r
+					record.Op = journalEnqueue
+					unfinished[r.ID] = &record
+				case journalRetry:
+					if record, ok := unfinished[r.ID]; ok {
+						record.RetryTimes = r.RetryTimes
+						record.RunAt = r.RunAt
+					}
+				case journalComplete, journalFail, journalCancel:
+					delete(unfinished, r.ID)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]journalRecord, 0, len(unfinished))
+	for _, id := range order {
+		if record, ok := unfinished[id]; ok {
+			records = append(records, *record)
+			delete(unfinished, id)
+		}
+	}
+	return records, nil
+}
+
+func newJournalRecord(op journalOp, t *task) journalRecord {
+	r := journalRecord{
+		Op:         op,
+		ID:         t.ID,
+		RetryTimes: t.retryTimes,
+		Time:       time.Now(),
+	}
+	if op == journalRetry {
+		r.RunAt = timePtr(t.runAt)
+	}
+	if op == journalEnqueue {
+		r.Preset = t.preset
+		r.Handler = t.handler
+		r.Payload = t.payload
+		r.Timeout = t.timeout
+		r.RetryOn = t.retryOn
+		r.RetryMax = t.retryMax
+		r.EnqueueAt = timePtr(t.enqueueAt)
+		r.RunAt = timePtr(t.runAt)
+		r.OrderingKey = t.orderingKey
+		r.Labels = t.labels
+		r.Deadline = timePtr(t.deadline)
+	}
+	return r
+}
+
+// * omitempty 對 time.Time 無效，零值以 nil 省略
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func (j *journal) write(op journalOp, t *task) {
+	// * closure 無法序列化，僅記錄具名 handler 的任務
+	if j == nil || t.handler == "" {
+		return
+	}
+	j.writeRecord(newJournalRecord(op, t))
+}
+
+func (j *journal) writeRecord(r journalRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		j.logger.log(slog.LevelError, "journal.write_failed", "id", r.ID, "op", r.Op, "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return
+	}
+	if _, err := j.file.Write(line); err != nil {
+		j.logger.log(slog.LevelError, "journal.write_failed", "id", r.ID, "op", r.Op, "error", err)
+		return
+	}
+	// * 每筆紀錄落盤後才返回，避免斷電遺失已回傳的任務
+	if err := j.file.Sync(); err != nil {
+		j.logger.log(slog.LevelError, "journal.sync_failed", "id", r.ID, "op", r.Op, "error", err)
+	}
+}
+
+func (j *journal) takeRecords() []journalRecord {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	records := j.records
+	j.records = nil
+	return records
+}
+
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+func (q *Queue) replay() {
+	for _, r := range q.journal.takeRecords() {
+		task, ok := q.restoreTask(r)
+		if !ok {
+			q.logger.log(slog.LevelWarn, "journal.handler_missing", "id", r.ID, "handler", r.Handler)
+			continue
+		}
+
+		q.status.enqueue(task)
+		info := newTaskInfo(task)
+		if err := q.pending.Push(task); err != nil {
+			q.logger.log(slog.LevelError, "journal.replay_failed", "id", r.ID, "handler", r.Handler, "error", err)
+			q.status.remove(task.ID)
+			continue
+		}
+		q.observe("enqueue", info, Observer.OnEnqueue)
+		q.logger.log(slog.LevelDebug, "journal.replayed", "id", r.ID, "handler", r.Handler, "retry_times", r.RetryTimes)
+	}
+	q.evict()
+}
+
+func (q *Queue) restoreTask(r journalRecord) (*task, bool) {
+	handler, ok := q.handlers.get(r.Handler)
+	if !ok {
+		return nil, false
+	}
+
+	priority := q.config.Preset[r.Preset].Priority
+	if r.RetryTimes > 0 {
+		priority = PriorityRetry
+	}
+
+	return &task{
+		ID:          r.ID,
+		preset:      r.Preset,
+		priority:    priority,
+		action:      bindHandler(handler, r.Payload),
+		handler:     r.Handler,
+		payload:     r.Payload,
+		timeout:     r.Timeout,
+		enqueueAt:   timeValue(r.EnqueueAt),
+		startAt:     timeValue(r.EnqueueAt),
+		runAt:       timeValue(r.RunAt),
+		retryOn:     r.RetryOn,
+		retryMax:    r.RetryMax,
+		retryTimes:  r.RetryTimes,
+		backoff:     q.config.Preset[r.Preset].Backoff,
+		orderingKey: r.OrderingKey,
+		labels:      r.Labels,
+		deadline:    timeValue(r.Deadline),
+	}, true
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+)
+
+// * 以事件名稱（如 "task.completed"）覆寫等級與取樣
+type logger struct {
+	base   *slog.Logger
+	levels map[string]slog.Level
+	sample map[string]uint64
+	counts map[string]*atomic.Uint64
+}
+
+func newLogger(base *slog.Logger, levels map[string]slog.Level, sample map[string]int) *logger {
+	l := &logger{
+		base:   base,
+		levels: make(map[string]slog.Level, len(levels)),
+		sample: make(map[string]uint64, len(sample)),
+		counts: make(map[string]*atomic.Uint64, len(sample)),
+	}
+	for event, level := range levels {
+		l.levels[event] = level
+	}
+	for event, n := range sample {
+		if n > 1 {
+			l.sample[event] = uint64(n)
+			l.counts[event] = &atomic.Uint64{}
+		}
+	}
+	return l
+}
+
+func (l *logger) log(level slog.Level, event string, args ...any) {
+	base := l.base
+	if base == nil {
+		base = slog.Default()
+	}
+	if override, ok := l.levels[event]; ok {
+		level = override
+	}
+
+	ctx := context.Background()
+	if !base.Enabled(ctx, level) {
+		return
+	}
+	// * 每 n 次僅輸出第一次
+	if n, ok := l.sample[event]; ok && (l.counts[event].Add(1)-1)%n != 0 {
+		return
+	}
+	base.Log(ctx, level, event, args...)
+}
+
+func WithLabels(labels map[string]string) EnqueueOption {
+	return func(c *enqueueConfig) {
+		if c.labels == nil {
+			c.labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			c.labels[k] = v
+		}
+	}
+}
+
+// * 無標籤時回傳空 Attr，handler 會略過
+func (t *task) labelAttr() slog.Attr {
+	if len(t.labels) == 0 {
+		return slog.Attr{}
+	}
+
+	keys := make([]string, 0, len(t.labels))
+	for k := range t.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.String(k, t.labels[k]))
+	}
+	return slog.Group("labels", attrs...)
+}
@@ -2,7 +2,13 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -498,3 +504,2001 @@ func TestShutdownTimeout(t *testing.T) {
 		t.Errorf("expected timeout error during shutdown")
 	}
 }
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+
+	var count atomic.Int32
+	handlers := map[string]Handler{
+		"count": func(ctx context.Context, payload []byte) error {
+			count.Add(int32(len(payload)))
+			return nil
+		},
+	}
+
+	ctx := context.Background()
+
+	// 未啟動即關閉，模擬重啟前遺留的任務
+	first := New(&Config{Workers: 1, Journal: path, Handlers: handlers})
+	for i := 0; i < 3; i++ {
+		if _, err := first.EnqueueNamed(ctx, "", "count", []byte("ab")); err != nil {
+			t.Fatalf("EnqueueNamed failed: %v", err)
+		}
+	}
+	first.Shutdown(ctx)
+
+	if count.Load() != 0 {
+		t.Fatalf("expected no execution before restart, got %d", count.Load())
+	}
+
+	second := New(&Config{Workers: 1, Journal: path, Handlers: handlers})
+	second.Start(ctx)
+	second.Shutdown(ctx)
+
+	if count.Load() != 6 {
+		t.Errorf("expected 6 after replay, got %d", count.Load())
+	}
+
+	third := New(&Config{Workers: 1, Journal: path, Handlers: handlers})
+	third.Start(ctx)
+	third.Shutdown(ctx)
+
+	if count.Load() != 6 {
+		t.Errorf("expected completed tasks not to replay, got %d", count.Load())
+	}
+
+	// * 重試無法入隊時記錄 fail，避免重啟後再次執行
+	failPath := filepath.Join(t.TempDir(), "fail.journal")
+	gate := make(chan struct{})
+	full := New(&Config{
+		Workers: 1,
+		Size:    1,
+		Journal: failPath,
+		Handlers: map[string]Handler{
+			"fail": func(ctx context.Context, payload []byte) error {
+				<-gate
+				return errors.New("boom")
+			},
+		},
+	})
+	full.Start(ctx)
+	failID, _ := full.EnqueueNamed(ctx, "", "fail", nil, WithRetry(1))
+	waitUntil(t, func() bool {
+		status, _ := full.Status(failID)
+		return status.State == StateRunning
+	})
+	full.Enqueue(ctx, "", func(ctx context.Context) error { return nil })
+	close(gate)
+	waitUntil(t, func() bool {
+		status, _ := full.Status(failID)
+		return status.State.Finished()
+	})
+	full.Shutdown(ctx)
+
+	if status, _ := full.Status(failID); status.State != StateFailed {
+		t.Errorf("expected failed after retry push, got %s", status.State)
+	}
+	if records, _ := loadJournal(failPath, full.logger); len(records) != 0 {
+		t.Errorf("expected failed task not to replay, got %+v", records)
+	}
+	if data, _ := os.ReadFile(failPath); strings.Contains(string(data), "0001-01-01") {
+		t.Errorf("expected zero times to be omitted, got %s", data)
+	}
+
+	broken := New(&Config{Workers: 1, Journal: filepath.Join(failPath, "queue.journal")})
+	if err := broken.Start(ctx); err == nil {
+		t.Errorf("expected Start to report journal open failure")
+	}
+	broken.Shutdown(ctx)
+}
+
+func TestRegisterHandler(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+
+	var received atomic.Value
+	err := queue.Register("echo", func(ctx context.Context, payload []byte) error {
+		received.Store(string(payload))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := queue.Register("echo", func(ctx context.Context, payload []byte) error { return nil }); err == nil {
+		t.Errorf("expected error when registering duplicate handler")
+	}
+
+	if _, err := queue.EnqueueNamed(ctx, "", "missing", nil); err == nil {
+		t.Errorf("expected error when handler is not registered")
+	}
+
+	payload := []byte("hello")
+	if _, err := queue.EnqueueNamed(ctx, "", "echo", payload); err != nil {
+		t.Fatalf("EnqueueNamed failed: %v", err)
+	}
+	payload[0] = 'j'
+
+	queue.Start(ctx)
+	queue.Shutdown(ctx)
+
+	if got, _ := received.Load().(string); got != "hello" {
+		t.Errorf("expected payload hello, got %q", got)
+	}
+
+	if names := queue.Handlers(); len(names) != 1 || names[0] != "echo" {
+		t.Errorf("expected [echo], got %v", names)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	queue := New(&Config{
+		Workers:   1,
+		Retention: 2,
+		Preset: map[string]PresetConfig{
+			"low": {Priority: PriorityLow},
+		},
+	})
+
+	ctx := context.Background()
+
+	okID, _ := queue.Enqueue(ctx, "low", func(ctx context.Context) error {
+		return nil
+	})
+	failID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, WithRetry(1))
+
+	status, ok := queue.Status(okID)
+	if !ok || status.State != StatePending || status.Priority != PriorityLow || status.Preset != "low" {
+		t.Fatalf("unexpected pending status: %+v", status)
+	}
+
+	queue.Start(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	status, _ = queue.Status(okID)
+	if status.State != StateSucceeded || status.Attempts != 1 || status.FinishedAt.IsZero() {
+		t.Errorf("unexpected succeeded status: %+v", status)
+	}
+
+	status, _ = queue.Status(failID)
+	if status.State != StateExhausted || status.Attempts != 2 || status.LastError == nil {
+		t.Errorf("unexpected exhausted status: %+v", status)
+	}
+
+	lastID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		return nil
+	})
+	queue.Shutdown(ctx)
+
+	// 空 preset 為 PriorityImmediate，failID 最先結束
+	if _, ok := queue.Status(failID); ok {
+		t.Errorf("expected oldest finished status to be evicted")
+	}
+	if _, ok := queue.Status(lastID); !ok {
+		t.Errorf("expected latest finished status to be retained")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+
+	var pendingRan atomic.Bool
+	pendingID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		pendingRan.Store(true)
+		return nil
+	})
+
+	result, err := queue.Cancel(pendingID)
+	if err != nil || result != CancelPending {
+		t.Fatalf("expected CancelPending, got %v (%v)", result, err)
+	}
+
+	started := make(chan struct{})
+	runningID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithRetry())
+
+	queue.Start(ctx)
+	<-started
+
+	result, err = queue.Cancel(runningID)
+	if err != nil || result != CancelRunning {
+		t.Fatalf("expected CancelRunning, got %v (%v)", result, err)
+	}
+
+	queue.Shutdown(ctx)
+
+	if pendingRan.Load() {
+		t.Errorf("canceled pending task should not run")
+	}
+
+	for _, id := range []string{pendingID, runningID} {
+		status, _ := queue.Status(id)
+		if status.State != StateCanceled {
+			t.Errorf("expected %s to be canceled, got %s", id, status.State)
+		}
+	}
+
+	if result, err := queue.Cancel("missing"); err == nil || result != CancelNotFound {
+		t.Errorf("expected CancelNotFound, got %v (%v)", result, err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var attempts atomic.Int32
+	future, err := Submit(queue, ctx, "", func(ctx context.Context) (int, error) {
+		if attempts.Add(1) < 3 {
+			return 0, errors.New("not yet")
+		}
+		return 42, nil
+	}, WithRetry(3))
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	value, err := future.Wait(waitCtx)
+	if err != nil || value != 42 {
+		t.Errorf("expected 42, got %d (%v)", value, err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts.Load())
+	}
+
+	failed, _ := Submit(queue, ctx, "", func(ctx context.Context) (string, error) {
+		return "ignored", errors.New("boom")
+	})
+
+	<-failed.Done()
+	value2, err := failed.Wait(ctx)
+	if err == nil || value2 != "" {
+		t.Errorf("expected zero value with error, got %q (%v)", value2, err)
+	}
+
+	if status, _ := queue.Status(failed.ID()); status.State != StateFailed {
+		t.Errorf("expected failed state, got %s", status.State)
+	}
+
+	// 逾時的舊嘗試晚於新嘗試寫入，不可覆寫結果
+	stale := &Future[int]{done: make(chan struct{})}
+	first := stale.begin()
+	second := stale.begin()
+	stale.set(second, 2)
+	stale.set(first, 1)
+	stale.resolve(StateSucceeded, nil)
+	if value, _ := stale.Wait(ctx); value != 2 {
+		t.Errorf("expected latest attempt value 2, got %d", value)
+	}
+
+	queue.Shutdown(ctx)
+}
+
+func TestDelay(t *testing.T) {
+	queue := New(&Config{Workers: 1, Size: 2})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var ranAt atomic.Int64
+	start := time.Now()
+
+	id, err := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		ranAt.Store(time.Now().UnixNano())
+		return nil
+	}, WithDelay(150*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	later, err := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		return nil
+	}, WithRunAt(start.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	// 延遲任務計入 Size
+	if _, err := queue.Enqueue(ctx, "", func(ctx context.Context) error { return nil }); err == nil {
+		t.Errorf("expected delayed tasks to count toward size")
+	}
+
+	delayed := queue.Delayed()
+	if len(delayed) != 2 || delayed[0].ID != id {
+		t.Fatalf("expected 2 delayed tasks ordered by due time, got %+v", delayed)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if ranAt.Load() == 0 {
+		t.Fatalf("delayed task did not run")
+	}
+	if waited := time.Duration(ranAt.Load() - start.UnixNano()); waited < 150*time.Millisecond {
+		t.Errorf("delayed task ran too early: %s", waited)
+	}
+	if len(queue.Delayed()) != 1 {
+		t.Errorf("expected 1 delayed task left, got %d", len(queue.Delayed()))
+	}
+
+	// 關閉時未到期的延遲任務以 canceled 結束
+	if err := queue.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if status, _ := queue.Status(later); status.State != StateCanceled {
+		t.Errorf("expected pending delayed task to be canceled on shutdown, got %s", status.State)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 30, 15, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"30 */10 * * * *", time.Date(2026, 1, 1, 10, 30, 30, 0, time.UTC)},
+		{"0 0 1 feb *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+
+	for _, c := range cases {
+		spec, err := parseCron(c.spec)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.spec, err)
+			continue
+		}
+		if got := spec.Next(base); !got.Equal(c.want) {
+			t.Errorf("%s: expected %s, got %s", c.spec, c.want, got)
+		}
+	}
+
+	for _, spec := range []string{"* * *", "61 * * * *", "@sometimes", "@every -1s"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("%s: expected parse error", spec)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	spec, err := parseCron("*/5 * * * *")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	// 2026-11-01 02:00 PDT 回撥至 01:00 PST，01:xx 出現兩次
+	pdt := time.Date(2026, 11, 1, 0, 50, 0, 0, loc)
+	pst := time.Date(2026, 11, 1, 1, 30, 0, 0, loc).Add(time.Hour)
+	if name, _ := pst.Zone(); name != "PST" {
+		t.Fatalf("expected PST, got %s", name)
+	}
+
+	if next := spec.Next(pst); !next.Equal(time.Date(2026, 11, 1, 2, 0, 0, 0, loc)) {
+		t.Errorf("expected 02:00 PST, got %s", next)
+	}
+
+	prev := pdt
+	for i := 0; i < 40; i++ {
+		next := spec.Next(prev)
+		if !next.After(prev) {
+			t.Fatalf("Next(%s) = %s, expected a later time", prev, next)
+		}
+		prev = next
+	}
+
+	// 2026-03-08 02:00 PST 跳至 03:00 PDT，02:30 不存在時順延
+	daily, _ := parseCron("30 2 * * *")
+	skipped := daily.Next(time.Date(2026, 3, 8, 0, 0, 0, 0, loc))
+	if !skipped.Equal(time.Date(2026, 3, 8, 3, 30, 0, 0, loc)) {
+		t.Errorf("expected 03:30 PDT, got %s", skipped)
+	}
+	if next := daily.Next(skipped); !next.Equal(time.Date(2026, 3, 9, 2, 30, 0, 0, loc)) {
+		t.Errorf("expected next day 02:30, got %s", next)
+	}
+}
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remain := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+			continue
+		}
+		remain = append(remain, w)
+	}
+	c.waiters = remain
+}
+
+func (c *fakeClock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduleOverlap(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	queue := New(&Config{Workers: 2, Clock: clock})
+
+	ctx := context.Background()
+
+	var runs atomic.Int32
+	release := make(chan struct{})
+	id, err := queue.Schedule("@every 1m", "", func(ctx context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}, WithOverlap(OverlapSkip))
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	queue.Start(ctx)
+
+	waitUntil(t, func() bool { return clock.Waiting() == 1 })
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return runs.Load() == 1 })
+
+	// 前一次仍在執行，本次應略過
+	waitUntil(t, func() bool { return clock.Waiting() == 1 })
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return clock.Waiting() == 1 })
+
+	close(release)
+	entry := queue.scheduler.entries[id]
+	waitUntil(t, func() bool {
+		entry.mu.Lock()
+		defer entry.mu.Unlock()
+		return entry.inflight == 0
+	})
+
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return runs.Load() == 2 })
+
+	if !queue.Unschedule(id) {
+		t.Errorf("expected Unschedule to find %s", id)
+	}
+
+	queue.Shutdown(ctx)
+
+	if runs.Load() != 2 {
+		t.Errorf("expected 2 runs, got %d", runs.Load())
+	}
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy BackoffStrategy
+		want     []time.Duration
+	}{
+		{"constant", ConstantBackoff(time.Second), []time.Duration{time.Second, time.Second, time.Second}},
+		{"linear", LinearBackoff(time.Second, 2*time.Second, 4*time.Second), []time.Duration{time.Second, 3 * time.Second, 4 * time.Second}},
+		{"exponential", ExponentialBackoff(time.Second, 3*time.Second), []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+	}
+
+	for _, c := range cases {
+		var prev time.Duration
+		for i, want := range c.want {
+			prev = c.strategy.Next(i+1, prev)
+			if prev != want {
+				t.Errorf("%s attempt %d: expected %s, got %s", c.name, i+1, want, prev)
+			}
+		}
+	}
+
+	jitter := DecorrelatedJitterBackoff(100*time.Millisecond, time.Second)
+	var prev time.Duration
+	for i := 1; i <= 20; i++ {
+		prev = jitter.Next(i, prev)
+		if prev < 100*time.Millisecond || prev > time.Second {
+			t.Errorf("jitter attempt %d out of range: %s", i, prev)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	queue := New(&Config{
+		Workers: 1,
+		Preset: map[string]PresetConfig{
+			"flaky": {Priority: PriorityNormal, Backoff: ConstantBackoff(100 * time.Millisecond)},
+		},
+	})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var mu sync.Mutex
+	var attempts []time.Time
+
+	id, _ := queue.Enqueue(ctx, "flaky", func(ctx context.Context) error {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		mu.Unlock()
+		return errors.New("flaky")
+	}, WithRetry(2))
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(id)
+		return status.State == StateRetrying
+	})
+	if delayed := queue.Delayed(); len(delayed) != 1 || delayed[0].ID != id {
+		t.Errorf("expected retry to wait in delayed area, got %+v", delayed)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	queue.Shutdown(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	for i := 1; i < len(attempts); i++ {
+		if gap := attempts[i].Sub(attempts[i-1]); gap < 100*time.Millisecond {
+			t.Errorf("retry %d ran after %s, expected backoff of 100ms", i, gap)
+		}
+	}
+}
+
+func TestRetryBackoffOnShutdown(t *testing.T) {
+	queue := New(&Config{
+		Workers: 2,
+		Preset: map[string]PresetConfig{
+			"slow": {Priority: PriorityNormal, Backoff: ConstantBackoff(time.Hour)},
+		},
+	})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var attempts atomic.Int32
+	future, _ := Submit(queue, ctx, "slow", func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		return 0, errors.New("flaky")
+	}, WithRetry(5))
+
+	release := make(chan struct{})
+	var running atomic.Bool
+	runningID, _ := queue.Enqueue(ctx, "slow", func(ctx context.Context) error {
+		running.Store(true)
+		<-release
+		return errors.New("flaky")
+	}, WithRetry(5))
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(future.ID())
+		return status.State == StateRetrying && running.Load()
+	})
+
+	// * 退避中與關閉期間產生的重試皆以取消結束，不略過退避
+	shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	if err := queue.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if attempts.Load() != 1 {
+		t.Errorf("expected backoff to be honored, got %d attempts", attempts.Load())
+	}
+	for _, id := range []string{future.ID(), runningID} {
+		if status, _ := queue.Status(id); status.State != StateCanceled || !errors.Is(status.LastError, ErrQueueClosed) {
+			t.Errorf("expected %s canceled, got %s (%v)", id, status.State, status.LastError)
+		}
+	}
+	if _, err := future.Wait(shutdownCtx); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestRetryPredicate(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var permanentAttempts atomic.Int32
+	permanentID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		permanentAttempts.Add(1)
+		return Permanent(errors.New("invalid input"))
+	}, WithRetry(3))
+
+	var panicAttempts atomic.Int32
+	panicID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		panicAttempts.Add(1)
+		panic("boom")
+	}, WithRetry(3), WithRetryIf(func(err error, attempt int) bool {
+		return !errors.Is(err, ErrPanic)
+	}))
+
+	var limitedAttempts atomic.Int32
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		limitedAttempts.Add(1)
+		return errors.New("flaky")
+	}, WithRetry(5), WithRetryIf(func(err error, attempt int) bool {
+		return attempt < 2
+	}))
+
+	time.Sleep(200 * time.Millisecond)
+	queue.Shutdown(ctx)
+
+	if permanentAttempts.Load() != 1 {
+		t.Errorf("permanent error should not retry, got %d attempts", permanentAttempts.Load())
+	}
+	if panicAttempts.Load() != 1 {
+		t.Errorf("panic should not retry, got %d attempts", panicAttempts.Load())
+	}
+	if limitedAttempts.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", limitedAttempts.Load())
+	}
+
+	for _, id := range []string{permanentID, panicID} {
+		if status, _ := queue.Status(id); status.State != StateFailed {
+			t.Errorf("expected %s to be failed, got %s", id, status.State)
+		}
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.log")
+	sink, err := NewFileDeadLetter(path)
+	if err != nil {
+		t.Fatalf("NewFileDeadLetter failed: %v", err)
+	}
+
+	queue := New(&Config{Workers: 1, DeadLetter: sink})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var fail atomic.Bool
+	fail.Store(true)
+	var succeeded atomic.Int32
+
+	id, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		if fail.Load() {
+			return errors.New("downstream down")
+		}
+		succeeded.Add(1)
+		return nil
+	}, WithRetry(1))
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		return Permanent(errors.New("bad request"))
+	})
+
+	waitUntil(t, func() bool {
+		list, _ := queue.DeadLetters()
+		return len(list) == 2
+	})
+
+	list, _ := queue.DeadLetters()
+	for _, entry := range list {
+		if entry.ID == id && (entry.State != StateExhausted || entry.Attempts != 2 || len(entry.Errors) != 2) {
+			t.Errorf("unexpected exhausted dead letter: %+v", entry)
+		}
+		if entry.ID != id && (entry.State != StateFailed || entry.Attempts != 1) {
+			t.Errorf("unexpected failed dead letter: %+v", entry)
+		}
+	}
+
+	reopened, err := NewFileDeadLetter(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if persisted, _ := reopened.List(); len(persisted) != 2 {
+		t.Errorf("expected 2 persisted dead letters, got %d", len(persisted))
+	}
+
+	fail.Store(false)
+	if err := queue.Requeue(id); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	waitUntil(t, func() bool { return succeeded.Load() == 1 })
+
+	if list, _ := queue.DeadLetters(); len(list) != 1 {
+		t.Errorf("expected requeued entry to leave the dead letter, got %d", len(list))
+	}
+
+	if err := queue.Purge(); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if list, _ := queue.DeadLetters(); len(list) != 0 {
+		t.Errorf("expected empty dead letter after purge, got %d", len(list))
+	}
+
+	// 關閉時一併關閉 sink 的檔案
+	queue.Shutdown(ctx)
+	if err := sink.Put(DeadLetter{ID: "late"}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected closed sink after shutdown, got %v", err)
+	}
+
+	// 損毀紀錄經由 Config.Logger 輸出
+	corrupt := filepath.Join(t.TempDir(), "corrupt.log")
+	os.WriteFile(corrupt, []byte("{broken\n"), 0o644)
+	corruptSink, err := NewFileDeadLetter(corrupt)
+	if err != nil {
+		t.Fatalf("NewFileDeadLetter failed: %v", err)
+	}
+	out := &lockedBuffer{}
+	logged := New(&Config{DeadLetter: corruptSink, Logger: slog.New(slog.NewJSONHandler(out, nil))})
+	logged.Shutdown(ctx)
+	if out.count("deadletter.corrupt_record") != 1 {
+		t.Errorf("expected corrupt record on queue logger")
+	}
+
+	// 僅保留 Retention 筆 closure 任務供 Requeue
+	capped := New(&Config{Workers: 1, Retention: 1, DeadLetter: NewMemoryDeadLetter()})
+	capped.Start(ctx)
+	first, _ := capped.Enqueue(ctx, "", func(ctx context.Context) error { return errors.New("boom") })
+	second, _ := capped.Enqueue(ctx, "", func(ctx context.Context) error { return errors.New("boom") })
+	waitUntil(t, func() bool {
+		list, _ := capped.DeadLetters()
+		return len(list) == 2
+	})
+	if err := capped.Requeue(first); err == nil {
+		t.Errorf("expected oldest closure to be dropped beyond retention")
+	}
+	if err := capped.Requeue(second); err != nil {
+		t.Errorf("expected latest closure to be requeued, got %v", err)
+	}
+	capped.Shutdown(ctx)
+}
+
+func TestPresetConcurrency(t *testing.T) {
+	queue := New(&Config{
+		Workers: 3,
+		Preset: map[string]PresetConfig{
+			"batch":  {Priority: PriorityHigh, MaxConcurrency: 1},
+			"urgent": {Priority: PriorityNormal, MinReserved: 1},
+		},
+	})
+
+	ctx := context.Background()
+
+	var batchCurrent, batchMax atomic.Int32
+	for i := 0; i < 4; i++ {
+		queue.Enqueue(ctx, "batch", func(ctx context.Context) error {
+			c := batchCurrent.Add(1)
+			for {
+				old := batchMax.Load()
+				if c <= old || batchMax.CompareAndSwap(old, c) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			batchCurrent.Add(-1)
+			return nil
+		})
+	}
+
+	var urgentAt atomic.Int64
+	queue.Enqueue(ctx, "urgent", func(ctx context.Context) error {
+		urgentAt.Store(time.Now().UnixNano())
+		return nil
+	})
+
+	start := time.Now()
+	queue.Start(ctx)
+	queue.Shutdown(ctx)
+
+	if batchMax.Load() != 1 {
+		t.Errorf("expected batch concurrency 1, got %d", batchMax.Load())
+	}
+	// batch 優先級較高，但達上限時不應擋住 urgent
+	if waited := time.Duration(urgentAt.Load() - start.UnixNano()); waited > 40*time.Millisecond {
+		t.Errorf("urgent task waited behind batch tasks: %s", waited)
+	}
+}
+
+func TestPresetReserved(t *testing.T) {
+	queue := New(&Config{
+		Workers: 2,
+		Preset: map[string]PresetConfig{
+			"batch":  {Priority: PriorityNormal},
+			"urgent": {Priority: PriorityHigh, MinReserved: 1},
+		},
+	})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	release := make(chan struct{})
+	var batchRunning atomic.Int32
+	for i := 0; i < 3; i++ {
+		queue.Enqueue(ctx, "batch", func(ctx context.Context) error {
+			batchRunning.Add(1)
+			<-release
+			batchRunning.Add(-1)
+			return nil
+		})
+	}
+
+	waitUntil(t, func() bool { return batchRunning.Load() == 1 })
+
+	done := make(chan struct{})
+	queue.Enqueue(ctx, "urgent", func(ctx context.Context) error {
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("urgent task did not use its reserved worker")
+	}
+
+	if batchRunning.Load() != 1 {
+		t.Errorf("batch should not take the reserved worker, got %d running", batchRunning.Load())
+	}
+
+	close(release)
+	queue.Shutdown(ctx)
+}
+
+func TestRateLimit(t *testing.T) {
+	queue := New(&Config{
+		Workers: 4,
+		Preset: map[string]PresetConfig{
+			"api": {Priority: PriorityNormal, RateLimit: RateLimit{Rate: 20, Burst: 2}},
+		},
+	})
+
+	state, ok := queue.RateLimit("api")
+	if !ok || state.Rate != 20 || state.Burst != 2 || state.Tokens != 2 {
+		t.Fatalf("unexpected rate limit state: %+v", state)
+	}
+
+	ctx := context.Background()
+
+	var count atomic.Int32
+	for i := 0; i < 6; i++ {
+		queue.Enqueue(ctx, "api", func(ctx context.Context) error {
+			count.Add(1)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	queue.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	if c := count.Load(); c != 2 {
+		t.Errorf("expected burst of 2, got %d", c)
+	}
+
+	waitUntil(t, func() bool { return count.Load() == 6 })
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("expected rate limited execution, finished in %s", elapsed)
+	}
+
+	queue.SetRateLimit("api", RateLimit{})
+	if _, ok := queue.RateLimit("api"); ok {
+		t.Errorf("expected rate limit to be removed")
+	}
+
+	for i := 0; i < 4; i++ {
+		queue.Enqueue(ctx, "api", func(ctx context.Context) error {
+			count.Add(1)
+			return nil
+		})
+	}
+	waitUntil(t, func() bool { return count.Load() == 10 })
+
+	queue.Shutdown(ctx)
+}
+
+func TestOrderingKey(t *testing.T) {
+	queue := New(&Config{Workers: 4})
+
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var order []int
+	var current, maxCurrent atomic.Int32
+	var failedOnce atomic.Bool
+
+	for i := 0; i < 5; i++ {
+		i := i
+		queue.Enqueue(ctx, "", func(ctx context.Context) error {
+			c := current.Add(1)
+			defer current.Add(-1)
+			for {
+				old := maxCurrent.Load()
+				if c <= old || maxCurrent.CompareAndSwap(old, c) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			// 第 2 個任務首次失敗，重試時仍須早於後續任務
+			if i == 1 && failedOnce.CompareAndSwap(false, true) {
+				return errors.New("retry me")
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			return nil
+		}, WithOrderingKey("account-1"), WithRetry(1))
+	}
+
+	var otherRan atomic.Bool
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		otherRan.Store(true)
+		return nil
+	}, WithOrderingKey("account-2"))
+
+	queue.Start(ctx)
+	waitUntil(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return otherRan.Load() && len(order) == 5
+	})
+	queue.Shutdown(ctx)
+
+	if maxCurrent.Load() != 1 {
+		t.Errorf("expected serial execution per key, max concurrent %d", maxCurrent.Load())
+	}
+	expected := []int{0, 1, 2, 3, 4}
+	if len(order) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+	for i, v := range expected {
+		if order[i] != v {
+			t.Errorf("position %d: expected %d, got %d", i, v, order[i])
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	noop := func(ctx context.Context) error { return nil }
+
+	reject := New(&Config{Workers: 1, Dedup: DedupReject})
+	if _, err := reject.Enqueue(ctx, "", noop, WithTaskID("order-1")); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := reject.Enqueue(ctx, "", noop, WithTaskID("order-1")); !errors.Is(err, ErrDuplicateTask) {
+		t.Errorf("expected ErrDuplicateTask, got %v", err)
+	}
+	if reject.pending.Len() != 1 {
+		t.Errorf("expected 1 pending task, got %d", reject.pending.Len())
+	}
+
+	existing := New(&Config{Workers: 1, Dedup: DedupReturnExisting})
+	existing.Enqueue(ctx, "", noop, WithTaskID("order-2"))
+	id, err := existing.Enqueue(ctx, "", noop, WithTaskID("order-2"))
+	if err != nil || id != "order-2" {
+		t.Errorf("expected existing ID, got %q (%v)", id, err)
+	}
+	if existing.pending.Len() != 1 {
+		t.Errorf("expected 1 pending task, got %d", existing.pending.Len())
+	}
+
+	unique := New(&Config{Workers: 1})
+	unique.Start(ctx)
+
+	var runs atomic.Int32
+	count := func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+	unique.Enqueue(ctx, "", count, WithTaskID("report"), WithUniqueFor(100*time.Millisecond))
+	waitUntil(t, func() bool { return runs.Load() == 1 })
+
+	if _, err := unique.Enqueue(ctx, "", count, WithTaskID("report"), WithUniqueFor(100*time.Millisecond)); !errors.Is(err, ErrDuplicateTask) {
+		t.Errorf("expected completed task to be suppressed, got %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := unique.Enqueue(ctx, "", count, WithTaskID("report"), WithUniqueFor(100*time.Millisecond)); err != nil {
+		t.Errorf("expected enqueue after window, got %v", err)
+	}
+	waitUntil(t, func() bool { return runs.Load() == 2 })
+
+	reject.Start(ctx)
+	reject.Shutdown(ctx)
+	existing.Start(ctx)
+	existing.Shutdown(ctx)
+	unique.Shutdown(ctx)
+}
+
+func TestDebounce(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	var runs atomic.Int32
+	var last atomic.Int32
+
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		i := int32(i)
+		id, err := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+			runs.Add(1)
+			last.Store(i)
+			return nil
+		}, WithDebounce("reindex", 80*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		ids = append(ids, id)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if ids[0] != ids[1] || ids[1] != ids[2] {
+		t.Errorf("expected debounced enqueues to share one ID, got %v", ids)
+	}
+	if len(queue.Delayed()) != 1 {
+		t.Errorf("expected 1 waiting task, got %d", len(queue.Delayed()))
+	}
+
+	waitUntil(t, func() bool { return runs.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+
+	if runs.Load() != 1 || last.Load() != 3 {
+		t.Errorf("expected single run of the last action, got runs=%d last=%d", runs.Load(), last.Load())
+	}
+
+	// 取代後沿用原本在 ordering key 中的位置
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	queue.Enqueue(ctx, "", record("stale"), WithOrderingKey("doc"), WithDebounce("save", 80*time.Millisecond))
+	queue.Enqueue(ctx, "", record("next"), WithOrderingKey("doc"))
+	queue.Enqueue(ctx, "", record("save"), WithOrderingKey("doc"), WithDebounce("save", 80*time.Millisecond))
+
+	waitUntil(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 2
+	})
+	mu.Lock()
+	if order[0] != "save" || order[1] != "next" {
+		t.Errorf("expected replacement to keep its key position, got %v", order)
+	}
+	mu.Unlock()
+
+	queue.Shutdown(ctx)
+
+	// 零延遲的 debounce 直接進入 Policy，enqueue 紀錄須早於 complete
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	journaled := New(&Config{
+		Workers:  1,
+		Journal:  path,
+		Handlers: map[string]Handler{"noop": func(ctx context.Context, payload []byte) error { return nil }},
+	})
+	journaled.Start(ctx)
+	for i := 0; i < 20; i++ {
+		journaled.EnqueueNamed(ctx, "", "noop", nil, WithDebounce("flush", 0))
+	}
+	journaled.Shutdown(ctx)
+
+	if records, _ := loadJournal(path, journaled.logger); len(records) != 0 {
+		t.Errorf("expected no unfinished journal records, got %d", len(records))
+	}
+
+	// * journal 於鎖外寫入，Publish 前任務不可被取出
+	held := New(&Config{Workers: 1})
+	mergeTask := held.newTask("", func(ctx context.Context) error { return nil }, []EnqueueOption{WithDebounce("held", 0)})
+	held.pending.Merge(ctx, mergeTask, func(t *task) bool { return true })
+	take := func() *task {
+		held.pending.mu.Lock()
+		defer held.pending.mu.Unlock()
+		return held.pending.takeLocked()
+	}
+	if take() != nil {
+		t.Errorf("expected held task not to be taken before Publish")
+	}
+	held.pending.Publish(mergeTask)
+	if take() != mergeTask {
+		t.Errorf("expected task to be taken after Publish")
+	}
+	held.Shutdown(ctx)
+}
+
+func TestCoalesce(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+
+	var first, dropped atomic.Int32
+	firstID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		first.Add(1)
+		return nil
+	}, WithCoalesce("cache:user:1"))
+
+	for i := 0; i < 3; i++ {
+		id, err := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+			dropped.Add(1)
+			return nil
+		}, WithCoalesce("cache:user:1"))
+		if err != nil || id != firstID {
+			t.Errorf("expected coalesced ID %s, got %s (%v)", firstID, id, err)
+		}
+	}
+
+	if queue.pending.Len() != 1 {
+		t.Errorf("expected 1 pending task, got %d", queue.pending.Len())
+	}
+
+	queue.Start(ctx)
+	waitUntil(t, func() bool { return first.Load() == 1 })
+
+	// 已開始執行後，同 key 可再次入隊
+	nextID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		first.Add(1)
+		return nil
+	}, WithCoalesce("cache:user:1"))
+	if nextID == firstID {
+		t.Errorf("expected a new task after the first one started")
+	}
+
+	queue.Shutdown(ctx)
+
+	if first.Load() != 2 || dropped.Load() != 0 {
+		t.Errorf("expected 2 kept runs and 0 dropped, got %d and %d", first.Load(), dropped.Load())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	queue := New(&Config{Workers: 1, Size: 1})
+
+	ctx := context.Background()
+	noop := func(ctx context.Context) error { return nil }
+
+	block := make(chan struct{})
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	if _, err := queue.Enqueue(ctx, "", noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	queue.Start(ctx)
+	if err := queue.Start(ctx); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+	waitUntil(t, func() bool { return queue.pending.Len() == 0 })
+
+	timeoutID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}, WithTimeout(20*time.Millisecond))
+	close(block)
+	waitUntil(t, func() bool { return queue.pending.Len() == 0 })
+
+	panicID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	waitUntil(t, func() bool {
+		a, _ := queue.Status(timeoutID)
+		b, _ := queue.Status(panicID)
+		return a.State.Finished() && b.State.Finished()
+	})
+
+	status, _ := queue.Status(timeoutID)
+	var timeoutErr *TimeoutError
+	if !errors.As(status.LastError, &timeoutErr) || !errors.Is(status.LastError, ErrTimeout) || timeoutErr.Timeout != 20*time.Millisecond {
+		t.Errorf("expected *TimeoutError, got %v", status.LastError)
+	}
+
+	status, _ = queue.Status(panicID)
+	var panicErr *PanicError
+	if !errors.As(status.LastError, &panicErr) || !errors.Is(status.LastError, ErrPanic) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("expected *PanicError with stack, got %v", status.LastError)
+	}
+
+	hold := make(chan struct{})
+	defer close(hold)
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-hold
+		return nil
+	})
+	queue.Enqueue(ctx, "", noop)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err := queue.Shutdown(shutdownCtx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected *ShutdownError, got %v", err)
+	}
+
+	if _, err := queue.Enqueue(ctx, "", noop); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+	if err := queue.Start(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected ErrQueueClosed on restart, got %v", err)
+	}
+
+	idle := New(&Config{Workers: 1})
+	if err := idle.Shutdown(ctx); err != nil {
+		t.Errorf("expected nil on Shutdown before Start, got %v", err)
+	}
+}
+
+func TestEnqueueWait(t *testing.T) {
+	queue := New(&Config{
+		Workers: 1,
+		Size:    1,
+		Preset: map[string]PresetConfig{
+			"low":  {Priority: PriorityLow},
+			"high": {Priority: PriorityHigh},
+		},
+	})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	waiters := func() int {
+		queue.pending.mu.Lock()
+		defer queue.pending.mu.Unlock()
+		return len(queue.pending.waiters)
+	}
+
+	block := make(chan struct{})
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	waitUntil(t, func() bool { return queue.pending.Len() == 0 })
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	queue.Enqueue(ctx, "", record("filler"))
+
+	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, err := queue.EnqueueWait(waitCtx, "", record("expired")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		queue.EnqueueWait(ctx, "low", record("low"))
+	}()
+	waitUntil(t, func() bool { return waiters() == 1 })
+	go func() {
+		defer wg.Done()
+		queue.Enqueue(ctx, "high", record("high"), WithBlock())
+	}()
+	waitUntil(t, func() bool { return waiters() == 2 })
+
+	close(block)
+	wg.Wait()
+	queue.Shutdown(ctx)
+
+	expected := []string{"filler", "high", "low"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, order)
+			break
+		}
+	}
+
+	// * 非阻塞入隊不得搶走同等或更高優先級等待者的空位
+	queue = New(&Config{
+		Workers: 1,
+		Size:    1,
+		Preset: map[string]PresetConfig{
+			"high":      {Priority: PriorityHigh},
+			"immediate": {Priority: PriorityImmediate},
+		},
+	})
+	queue.pending.waiters = append(queue.pending.waiters, &spaceWaiter{priority: PriorityHigh})
+	if _, err := queue.Enqueue(ctx, "high", record("taken")); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull while a waiter is queued, got %v", err)
+	}
+	if _, err := queue.Enqueue(ctx, "immediate", record("ahead")); err != nil {
+		t.Errorf("expected higher priority to enqueue, got %v", err)
+	}
+	queue.pending.waiters = nil
+	queue.Shutdown(ctx)
+}
+
+func TestOverflowEvict(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	presets := map[string]PresetConfig{
+		"low":  {Priority: PriorityLow},
+		"high": {Priority: PriorityHigh},
+	}
+	ctx := context.Background()
+
+	var evicted []EvictedTask
+	queue := New(&Config{
+		Workers:        1,
+		Size:           2,
+		Preset:         presets,
+		OverflowPolicy: OverflowEvictLowest,
+		OnEvict: func(task EvictedTask) {
+			evicted = append(evicted, task)
+		},
+	})
+
+	oldest, _ := queue.Enqueue(ctx, "low", noop)
+	queue.Enqueue(ctx, "low", noop)
+	if _, err := queue.Enqueue(ctx, "low", noop); err != nil {
+		t.Fatalf("expected eviction to make room, got %v", err)
+	}
+
+	if len(evicted) != 1 || evicted[0].ID != oldest || evicted[0].Action == nil {
+		t.Fatalf("expected oldest low task to be evicted, got %+v", evicted)
+	}
+	if status, _ := queue.Status(oldest); status.State != StateEvicted || !errors.Is(status.LastError, ErrEvicted) {
+		t.Errorf("expected evicted state, got %s (%v)", status.State, status.LastError)
+	}
+	queue.Shutdown(ctx)
+
+	queue = New(&Config{
+		Workers:        1,
+		Size:           2,
+		Preset:         presets,
+		OverflowPolicy: OverflowEvictLower,
+	})
+
+	first, _ := queue.Enqueue(ctx, "high", noop)
+	queue.Enqueue(ctx, "high", noop)
+	if _, err := queue.Enqueue(ctx, "low", noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull for lower priority, got %v", err)
+	}
+	if _, err := queue.Enqueue(ctx, "", noop); err != nil {
+		t.Errorf("expected higher priority to evict, got %v", err)
+	}
+	if status, _ := queue.Status(first); status.State != StateEvicted {
+		t.Errorf("expected %s to be evicted, got %s", first, status.State)
+	}
+	queue.Shutdown(ctx)
+
+	// * 重複 ID 時僅移除被選中的任務
+	var evictedRan, keptRan atomic.Bool
+	queue = New(&Config{
+		Workers:        1,
+		Size:           2,
+		Preset:         presets,
+		OverflowPolicy: OverflowEvictLowest,
+	})
+	queue.Enqueue(ctx, "low", func(ctx context.Context) error {
+		evictedRan.Store(true)
+		return nil
+	}, WithTaskID("dup"))
+	queue.Enqueue(ctx, "high", func(ctx context.Context) error {
+		keptRan.Store(true)
+		return nil
+	}, WithTaskID("dup"))
+	queue.Enqueue(ctx, "low", noop)
+	if queue.pending.Len() != 2 {
+		t.Errorf("expected 2 pending after eviction, got %d", queue.pending.Len())
+	}
+
+	queue.Start(ctx)
+	waitUntil(t, keptRan.Load)
+	queue.Shutdown(ctx)
+	if evictedRan.Load() {
+		t.Error("expected evicted duplicate not to run")
+	}
+}
+
+func TestOverflowSpill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overflow.jsonl")
+
+	missing := New(&Config{Workers: 1, OverflowPolicy: OverflowSpill})
+	if err := missing.Start(context.Background()); err == nil {
+		t.Errorf("expected Start to report missing OverflowPath")
+	}
+	missing.Shutdown(context.Background())
+
+	var mu sync.Mutex
+	var order []string
+	queue := New(&Config{
+		Workers:        1,
+		Size:           1,
+		OverflowPolicy: OverflowSpill,
+		OverflowPath:   path,
+		OverflowLimit:  4,
+		Handlers: map[string]Handler{
+			"record": func(ctx context.Context, payload []byte) error {
+				mu.Lock()
+				order = append(order, string(payload))
+				mu.Unlock()
+				return nil
+			},
+		},
+	})
+
+	ctx := context.Background()
+	var callbacks atomic.Int32
+	callback := WithCallback(func(id string) { callbacks.Add(1) })
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if _, err := queue.EnqueueNamed(ctx, "", "record", []byte(name), WithOrderingKey("k"), callback); err != nil {
+			t.Fatalf("EnqueueNamed failed: %v", err)
+		}
+	}
+	canceled, _ := queue.EnqueueNamed(ctx, "", "record", []byte("x"), WithOrderingKey("k"))
+	if _, err := queue.EnqueueNamed(ctx, "", "record", []byte("y")); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull beyond OverflowLimit, got %v", err)
+	}
+	if _, err := queue.Enqueue(ctx, "", func(ctx context.Context) error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected closure task to be rejected, got %v", err)
+	}
+	if queue.pending.Len() != 5 {
+		t.Errorf("expected 5 pending including spilled, got %d", queue.pending.Len())
+	}
+	if result, err := queue.Cancel(canceled); result != CancelPending || err != nil {
+		t.Errorf("expected spilled task to be canceled, got %v (%v)", result, err)
+	}
+	if status, _ := queue.Status(canceled); status.State != StateCanceled {
+		t.Errorf("expected spilled task canceled, got %s", status.State)
+	}
+
+	queue.Start(ctx)
+	waitUntil(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 4
+	})
+	queue.Shutdown(ctx)
+
+	if callbacks.Load() != 4 {
+		t.Errorf("expected callbacks for spilled tasks, got %d", callbacks.Load())
+	}
+	expected := []string{"a", "b", "c", "d"}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, order)
+			break
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("expected overflow file to be truncated, got %v (%v)", info, err)
+	}
+}
+
+type recordingObserver struct {
+	BaseObserver
+	mu     sync.Mutex
+	events map[string][]TaskInfo
+}
+
+func (o *recordingObserver) record(event string, info TaskInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events[event] = append(o.events[event], info)
+}
+
+func (o *recordingObserver) count(event string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.events[event])
+}
+
+func (o *recordingObserver) OnEnqueue(info TaskInfo)   { o.record("enqueue", info) }
+func (o *recordingObserver) OnStart(info TaskInfo)     { o.record("start", info) }
+func (o *recordingObserver) OnSuccess(info TaskInfo)   { o.record("success", info) }
+func (o *recordingObserver) OnFailure(info TaskInfo)   { o.record("failure", info) }
+func (o *recordingObserver) OnRetry(info TaskInfo)     { o.record("retry", info) }
+func (o *recordingObserver) OnExhausted(info TaskInfo) { o.record("exhausted", info) }
+func (o *recordingObserver) OnTimeout(info TaskInfo)   { o.record("timeout", info) }
+func (o *recordingObserver) OnPanic(info TaskInfo)     { o.record("panic", info) }
+
+type panickingObserver struct {
+	BaseObserver
+}
+
+func (panickingObserver) OnStart(TaskInfo) { panic("observer") }
+
+func TestObserver(t *testing.T) {
+	observer := &recordingObserver{events: make(map[string][]TaskInfo)}
+	queue := New(&Config{
+		Workers:   1,
+		Observers: []Observer{panickingObserver{}, observer},
+	})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	queue.Enqueue(ctx, "", func(ctx context.Context) error { return nil })
+	failID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, WithRetry(1))
+	queue.Enqueue(ctx, "", func(ctx context.Context) error { panic("boom") })
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(20*time.Millisecond))
+
+	waitUntil(t, func() bool {
+		return observer.count("success")+observer.count("failure")+observer.count("exhausted") == 4
+	})
+	queue.Shutdown(ctx)
+
+	expected := map[string]int{
+		"enqueue":   4,
+		"start":     5,
+		"success":   1,
+		"retry":     1,
+		"exhausted": 1,
+		"failure":   2,
+		"panic":     1,
+		"timeout":   1,
+	}
+	for event, n := range expected {
+		if got := observer.count(event); got != n {
+			t.Errorf("expected %d %s events, got %d", n, event, got)
+		}
+	}
+
+	exhausted := observer.events["exhausted"][0]
+	if exhausted.ID != failID || exhausted.Attempt != 2 || exhausted.RetryMax != 1 || exhausted.Err == nil {
+		t.Errorf("unexpected exhausted info: %+v", exhausted)
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) count(s string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Count(b.buf.String(), s)
+}
+
+func TestLogger(t *testing.T) {
+	out := &lockedBuffer{}
+	queue := New(&Config{
+		Workers:   1,
+		Logger:    slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelInfo})),
+		LogLevels: map[string]slog.Level{"task.completed": slog.LevelDebug},
+		LogSample: map[string]int{"task.failed": 3},
+	})
+
+	ctx := context.Background()
+
+	id, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error { return nil },
+		WithLabels(map[string]string{"tenant": "acme"}))
+	queue.Cancel(id)
+
+	queue.Start(ctx)
+	for i := 0; i < 6; i++ {
+		queue.Enqueue(ctx, "", func(ctx context.Context) error { return errors.New("boom") })
+		queue.Enqueue(ctx, "", func(ctx context.Context) error { return nil })
+	}
+	queue.Shutdown(ctx)
+
+	if n := out.count(`"msg":"task.completed"`); n != 0 {
+		t.Errorf("expected completion lines to be lowered to debug, got %d", n)
+	}
+	if n := out.count(`"msg":"task.failed"`); n != 2 {
+		t.Errorf("expected 2 sampled failure lines, got %d", n)
+	}
+	if n := out.count(`"labels":{"tenant":"acme"}`); n != 1 {
+		t.Errorf("expected labels on the canceled line, got %d", n)
+	}
+}
+
+type traceKey struct{}
+type tenantKey struct{}
+
+func TestContextPropagation(t *testing.T) {
+	cases := []struct {
+		name       string
+		propagator ContextPropagator
+		trace      any
+		tenant     any
+	}{
+		{"none", nil, nil, nil},
+		{"all", PropagateAll(), "trace-1", "acme"},
+		{"keys", PropagateKeys(traceKey{}), "trace-1", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			queue := New(&Config{Workers: 1, ContextPropagator: c.propagator})
+
+			enqueueCtx, cancel := context.WithCancel(context.Background())
+			enqueueCtx = context.WithValue(enqueueCtx, traceKey{}, "trace-1")
+			enqueueCtx = context.WithValue(enqueueCtx, tenantKey{}, "acme")
+
+			var trace, tenant any
+			var ctxErr error
+			queue.Enqueue(enqueueCtx, "", func(ctx context.Context) error {
+				trace = ctx.Value(traceKey{})
+				tenant = ctx.Value(tenantKey{})
+				ctxErr = ctx.Err()
+				return nil
+			})
+			// * 請求結束後任務仍應執行，取消只跟隨佇列
+			cancel()
+
+			ctx := context.Background()
+			queue.Start(ctx)
+			queue.Shutdown(ctx)
+
+			if trace != c.trace || tenant != c.tenant {
+				t.Errorf("expected trace=%v tenant=%v, got %v %v", c.trace, c.tenant, trace, tenant)
+			}
+			if ctxErr != nil {
+				t.Errorf("expected live task context, got %v", ctxErr)
+			}
+		})
+	}
+}
+
+func TestBoundContext(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+
+	reqCtx, cancelReq := context.WithCancel(ctx)
+	var pendingRan atomic.Bool
+	pendingID, _ := queue.Enqueue(reqCtx, "", func(ctx context.Context) error {
+		pendingRan.Store(true)
+		return nil
+	}, WithBoundContext())
+	cancelReq()
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(pendingID)
+		return status.State == StateCanceled
+	})
+
+	queue.Start(ctx)
+
+	runCtx, cancelRun := context.WithCancel(ctx)
+	started := make(chan struct{})
+	runningID, _ := queue.Enqueue(runCtx, "", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithBoundContext())
+	<-started
+	cancelRun()
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(runningID)
+		return status.State == StateCanceled
+	})
+
+	// * enqueue ctx 的截止時間成為任務的絕對截止時間
+	deadlineCtx, cancelDeadline := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancelDeadline()
+	deadlineID, _ := queue.Enqueue(deadlineCtx, "", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithBoundContext(), WithTimeout(time.Minute))
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(deadlineID)
+		return status.State.Finished()
+	})
+	status, _ := queue.Status(deadlineID)
+	var timeoutErr *TimeoutError
+	if status.State != StateFailed || !errors.As(status.LastError, &timeoutErr) || timeoutErr.Timeout > 30*time.Millisecond {
+		t.Errorf("expected deadline timeout, got %s (%v)", status.State, status.LastError)
+	}
+
+	// * 未綁定的任務不受 enqueue ctx 影響
+	freeCtx, cancelFree := context.WithCancel(ctx)
+	var freeRan atomic.Bool
+	queue.Enqueue(freeCtx, "", func(ctx context.Context) error {
+		freeRan.Store(true)
+		return nil
+	})
+	cancelFree()
+
+	queue.Shutdown(ctx)
+
+	if pendingRan.Load() {
+		t.Errorf("expected bound pending task to be withdrawn")
+	}
+	if !freeRan.Load() {
+		t.Errorf("expected unbound task to run")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	queue := New(&Config{Workers: 1})
+
+	ctx := context.Background()
+	queue.Start(ctx)
+
+	block := make(chan struct{})
+	queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	var ran atomic.Bool
+	staleID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	}, WithTTL(20*time.Millisecond))
+
+	if status, _ := queue.Status(staleID); status.Deadline.IsZero() {
+		t.Errorf("expected deadline on status")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	close(block)
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(staleID)
+		return status.State.Finished()
+	})
+	status, _ := queue.Status(staleID)
+	if status.State != StateExpired || !errors.Is(status.LastError, ErrExpired) {
+		t.Errorf("expected expired, got %s (%v)", status.State, status.LastError)
+	}
+
+	cappedID, _ := queue.Enqueue(ctx, "", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(time.Minute), WithDeadline(time.Now().Add(30*time.Millisecond)))
+
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(cappedID)
+		return status.State.Finished()
+	})
+	status, _ = queue.Status(cappedID)
+	var timeoutErr *TimeoutError
+	if !errors.As(status.LastError, &timeoutErr) || timeoutErr.Timeout > 30*time.Millisecond {
+		t.Errorf("expected timeout capped by deadline, got %v", status.LastError)
+	}
+
+	queue.Shutdown(ctx)
+
+	if ran.Load() {
+		t.Errorf("expected expired task not to run")
+	}
+	if queue.pending.deadlines != 0 {
+		t.Errorf("expected no tracked deadlines after drain, got %d", queue.pending.deadlines)
+	}
+
+	// * TTL 自每次入隊起算
+	queue = New(&Config{Workers: 1})
+	queue.Start(ctx)
+
+	var runs atomic.Int32
+	count := func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+	options := []EnqueueOption{WithTTL(30 * time.Millisecond)}
+	time.Sleep(50 * time.Millisecond)
+	reusedID, _ := queue.Enqueue(ctx, "", count, options...)
+	waitUntil(t, func() bool {
+		status, _ := queue.Status(reusedID)
+		return status.State.Finished()
+	})
+	if status, _ := queue.Status(reusedID); status.State != StateSucceeded {
+		t.Errorf("expected reused TTL option to start fresh, got %s", status.State)
+	}
+
+	runs.Store(0)
+	queue.Schedule("@every 50ms", "", count, WithTTL(80*time.Millisecond))
+	time.Sleep(400 * time.Millisecond)
+	queue.Shutdown(ctx)
+	if runs.Load() < 4 {
+		t.Errorf("expected scheduled ticks to run, got %d", runs.Load())
+	}
+}
+
+func TestSchedulerEDF(t *testing.T) {
+	presets := map[string]PresetConfig{
+		"low":  {Priority: PriorityLow},
+		"high": {Priority: PriorityHigh},
+	}
+
+	run := func(mode SchedulerMode) []string {
+		queue := New(&Config{Workers: 1, Preset: presets, Scheduler: mode})
+
+		var mu sync.Mutex
+		var order []string
+		record := func(name string) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		ctx := context.Background()
+		now := time.Now()
+		queue.Enqueue(ctx, "", record("none"))
+		queue.Enqueue(ctx, "low", record("late"), WithDeadline(now.Add(3*time.Second)))
+		queue.Enqueue(ctx, "low", record("soon-low"), WithDeadline(now.Add(time.Second)))
+		queue.Enqueue(ctx, "high", record("soon-high"), WithDeadline(now.Add(time.Second)))
+		queue.Enqueue(ctx, "low", record("mid"), WithDeadline(now.Add(2*time.Second)))
+
+		queue.Start(ctx)
+		queue.Shutdown(ctx)
+		return order
+	}
+
+	cases := []struct {
+		mode     SchedulerMode
+		expected []string
+	}{
+		{SchedulerEDF, []string{"soon-high", "soon-low", "mid", "late", "none"}},
+		{SchedulerPriority, []string{"none", "soon-high", "late", "soon-low", "mid"}},
+	}
+	for _, c := range cases {
+		order := run(c.mode)
+		if len(order) != len(c.expected) {
+			t.Fatalf("mode %d: expected %v, got %v", c.mode, c.expected, order)
+		}
+		for i := range c.expected {
+			if order[i] != c.expected[i] {
+				t.Errorf("mode %d: expected %v, got %v", c.mode, c.expected, order)
+				break
+			}
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	presets := map[string]PresetConfig{
+		"low":    {Priority: PriorityLow},
+		"high":   {Priority: PriorityHigh},
+		"normal": {Priority: PriorityNormal},
+		"heavy":  {},
+		"light":  {},
+	}
+
+	type entry struct {
+		name    string
+		preset  string
+		timeout time.Duration
+	}
+	mixed := []entry{{"a", "low", 0}, {"b", "high", 0}, {"c", "normal", 0}}
+
+	cases := []struct {
+		name     string
+		policy   Policy
+		entries  []entry
+		expected []string
+	}{
+		{"fifo", FIFOPolicy(), mixed, []string{"a", "b", "c"}},
+		{"lifo", LIFOPolicy(), mixed, []string{"c", "b", "a"}},
+		{"priority", PriorityPolicy(), mixed, []string{"b", "c", "a"}},
+		{"aging", AgingPolicy(AgingRule{From: PriorityLow, To: PriorityHigh}), mixed, []string{"a", "b", "c"}},
+		{
+			"fair",
+			WeightedFairPolicy(map[string]int{"heavy": 2}),
+			[]entry{{"h1", "heavy", 0}, {"h2", "heavy", 0}, {"h3", "heavy", 0}, {"h4", "heavy", 0}, {"l1", "light", 0}, {"l2", "light", 0}},
+			[]string{"h1", "h2", "l1", "h3", "h4", "l2"},
+		},
+		{
+			"sjf",
+			ShortestJobPolicy(nil),
+			[]entry{{"long", "", 3 * time.Second}, {"short", "", time.Second}, {"mid", "", 2 * time.Second}},
+			[]string{"short", "mid", "long"},
+		},
+	}
+	for _, c := range cases {
+		queue := New(&Config{Workers: 1, Preset: presets, Policy: c.policy})
+
+		var mu sync.Mutex
+		var order []string
+		ctx := context.Background()
+		for _, e := range c.entries {
+			name := e.name
+			var options []EnqueueOption
+			if e.timeout > 0 {
+				options = append(options, WithTimeout(e.timeout))
+			}
+			queue.Enqueue(ctx, e.preset, func(ctx context.Context) error {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return nil
+			}, options...)
+		}
+
+		queue.Start(ctx)
+		queue.Shutdown(ctx)
+
+		if len(order) != len(c.expected) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.expected, order)
+		}
+		for i := range c.expected {
+			if order[i] != c.expected[i] {
+				t.Errorf("%s: expected %v, got %v", c.name, c.expected, order)
+				break
+			}
+		}
+	}
+}
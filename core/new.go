@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"runtime"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,33 +23,66 @@ const (
 )
 
 type Queue struct {
-	config  *Config
-	pending *pending
-	ctx     context.Context
-	cancel  context.CancelFunc
-	wg      sync.WaitGroup
-	state   atomic.Uint32
+	config    *Config
+	pending   *pending
+	journal   *journal
+	handlers  *handlerRegistry
+	status    *statusStore
+	scheduler *scheduler
+	deadMu    sync.Mutex
+	deadTasks map[string]deadTask
+	deadOrder []string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	state     atomic.Uint32
+	logger    *logger
+	openErr   error
 }
 
 type Config struct {
-	Workers int                     // default = CPU * 2
-	Size    int                     // default = Workers * 64
-	Timeout time.Duration           // default = 30 * seconds
-	Preset  map[string]PresetConfig // default = empty
+	Workers           int                     // default = CPU * 2
+	Size              int                     // default = Workers * 64
+	Timeout           time.Duration           // default = 30 * seconds
+	Preset            map[string]PresetConfig // default = empty
+	Journal           string                  // default = "" (disabled)
+	Handlers          map[string]Handler      // default = empty
+	Retention         int                     // default = 1024 finished tasks
+	Clock             Clock                   // default = system clock (Schedule only)
+	DeadLetter        DeadLetterSink          // default = nil (disabled)
+	Dedup             DedupMode               // default = DedupOff
+	OverflowPolicy    OverflowPolicy          // default = OverflowReject
+	OverflowPath      string                  // default = "" (required by OverflowSpill)
+	OverflowLimit     int                     // default = Size, max spilled tasks
+	OnEvict           func(EvictedTask)       // default = nil
+	Observers         []Observer              // default = empty
+	Logger            *slog.Logger            // default = slog.Default()
+	LogLevels         map[string]slog.Level   // default = empty, level override by event name
+	LogSample         map[string]int          // default = empty, log 1 of every N by event name
+	ContextPropagator ContextPropagator       // default = nil (values from the Enqueue ctx are dropped)
+	Scheduler         SchedulerMode           // default = SchedulerPriority
+	Policy            Policy                  // default = nil (aging priority, ordered by Scheduler)
 }
 
 type PresetConfig struct {
-	Priority Priority      // nil = 用 PriorityNormal
-	Timeout  time.Duration // 0 = 依 Priority 自動計算（秒）
+	Priority       Priority        // nil = 用 PriorityNormal
+	Timeout        time.Duration   // 0 = 依 Priority 自動計算（秒）
+	Backoff        BackoffStrategy // nil = 立即重試
+	MaxConcurrency int             // 0 = 不限制，最多同時占用的 worker 數
+	MinReserved    int             // 0 = 不保留，為此 preset 保留的 worker 數
+	RateLimit      RateLimit       // Rate 0 = 不限制
 }
 
 func New(config *Config) *Queue {
 	worker := runtime.NumCPU() * 2
 	newConfig := &Config{
-		Workers: worker,
-		Size:    worker * 64,
-		Timeout: 30 * time.Second,
-		Preset:  make(map[string]PresetConfig),
+		Workers:   worker,
+		Size:      worker * 64,
+		Timeout:   30 * time.Second,
+		Preset:    make(map[string]PresetConfig),
+		Handlers:  make(map[string]Handler),
+		Retention: 1024,
+		Clock:     systemClock{},
 	}
 
 	if config != nil {
@@ -66,29 +101,97 @@ func New(config *Config) *Queue {
 				newConfig.Preset[k] = v
 			}
 		}
+		if config.Retention != 0 {
+			newConfig.Retention = config.Retention
+		}
+		if config.Clock != nil {
+			newConfig.Clock = config.Clock
+		}
+		newConfig.DeadLetter = config.DeadLetter
+		newConfig.Dedup = config.Dedup
+		newConfig.OverflowPolicy = config.OverflowPolicy
+		newConfig.OverflowPath = config.OverflowPath
+		newConfig.OverflowLimit = config.OverflowLimit
+		newConfig.OnEvict = config.OnEvict
+		newConfig.Observers = append([]Observer(nil), config.Observers...)
+		newConfig.Logger = config.Logger
+		newConfig.LogLevels = config.LogLevels
+		newConfig.LogSample = config.LogSample
+		newConfig.ContextPropagator = config.ContextPropagator
+		newConfig.Scheduler = config.Scheduler
+		newConfig.Policy = config.Policy
+		newConfig.Journal = config.Journal
+		for k, v := range config.Handlers {
+			newConfig.Handlers[k] = v
+		}
+	}
+
+	if newConfig.OverflowLimit == 0 {
+		newConfig.OverflowLimit = newConfig.Size
 	}
 
 	q := &Queue{
-		config: newConfig,
+		config:    newConfig,
+		logger:    newLogger(newConfig.Logger, newConfig.LogLevels, newConfig.LogSample),
+		handlers:  newHandlerRegistry(newConfig.Handlers),
+		status:    newStatusStore(newConfig.Retention),
+		scheduler: newScheduler(newConfig.Clock),
+		deadTasks: make(map[string]deadTask),
 	}
 	q.state.Store(uint32(stateCreated))
-	q.pending = newPending(newConfig.Workers, newConfig.Size, newConfig.getPromotion(), &q.state)
+	checkReserved(newConfig.Workers, newConfig.Preset, q.logger)
+	if sink, ok := newConfig.DeadLetter.(deadLetterLogger); ok {
+		sink.setLogger(q.logger)
+	}
+	q.pending = newPending(newConfig.Workers, newConfig.Size, newConfig.getPolicy(), newConfig.Preset, &q.state)
+	q.pending.overflow = newConfig.OverflowPolicy
+	q.pending.rebind = q.rebindTask
+	q.pending.logger = q.logger
+
+	if newConfig.OverflowPolicy == OverflowSpill {
+		if newConfig.OverflowPath == "" {
+			q.logger.log(slog.LevelWarn, "overflow.path_missing")
+			q.openErr = errors.Join(q.openErr, errors.New("overflow spill requires OverflowPath"))
+		} else if spill, err := openSpill(newConfig.OverflowPath, newConfig.OverflowLimit, q.logger); err != nil {
+			q.logger.log(slog.LevelError, "overflow.open_failed", "path", newConfig.OverflowPath, "error", err)
+			q.openErr = errors.Join(q.openErr, fmt.Errorf("open overflow file: %w", err))
+		} else {
+			q.pending.spill = spill
+		}
+	}
+
+	if newConfig.Journal != "" {
+		journal, err := openJournal(newConfig.Journal, q.logger)
+		if err != nil {
+			q.logger.log(slog.LevelError, "journal.open_failed", "path", newConfig.Journal, "error", err)
+			q.openErr = errors.Join(q.openErr, fmt.Errorf("open journal: %w", err))
+		} else {
+			q.journal = journal
+		}
+	}
 	return q
 }
 
+// * journal 或溢出檔開啟失敗時不啟動，避免在無持久化的狀態下執行
 func (q *Queue) Start(ctx context.Context) error {
+	if q.openErr != nil {
+		return q.openErr
+	}
 	if !q.state.CompareAndSwap(uint32(stateCreated), uint32(stateRunning)) {
 		current := queueState(q.state.Load())
 		switch current {
 		case stateRunning:
-			return fmt.Errorf("queue already started")
+			return ErrAlreadyStarted
 		case stateClosed:
-			return fmt.Errorf("queue already closed")
+			return ErrQueueClosed
 		}
 	}
 
 	q.ctx, q.cancel = context.WithCancel(ctx)
 
+	q.replay()
+	q.startScheduler()
+
 	for i := 0; i < q.config.Workers; i++ {
 		q.wg.Add(1)
 		go q.worker()
@@ -101,14 +204,21 @@ func (q *Queue) worker() {
 	defer q.wg.Done()
 
 	for {
-		task, promotions, ok := q.pending.Pop()
+		task, promotions, expired, ok := q.pending.Pop()
+
+		for _, e := range promotions {
+			q.logger.log(slog.LevelDebug, "task.promoted", "id", e.taskID, "from", e.from, "to", e.to)
+			q.status.promote(e.taskID, e.to)
+			q.observe("promote", e.info, Observer.OnPromote)
+		}
+		q.expire(expired)
+		q.evict()
 
 		if !ok {
 			return
 		}
-
-		for _, e := range promotions {
-			slog.Debug("task.promoted", "id", e.taskID, "from", e.from, "to", e.to)
+		if task == nil {
+			continue
 		}
 
 		q.execute(task)
@@ -116,10 +226,23 @@ func (q *Queue) worker() {
 }
 
 func (q *Queue) execute(task *task) {
-	ctx, cancel := context.WithTimeout(q.ctx, task.timeout)
+	// * 絕對截止時間早於逾時則以截止時間為準
+	timeout := task.timeout
+	if !task.deadline.IsZero() {
+		timeout = min(timeout, time.Until(task.deadline))
+	}
+	ctx, cancel := context.WithTimeout(q.taskContext(task), timeout)
 	defer cancel()
+	task.setCancel(cancel)
 
 	start := time.Now()
+	q.journal.write(journalStart, task)
+	q.status.start(task, start)
+
+	info := newTaskInfo(task)
+	info.Attempt++
+	info.StartedAt = start
+	q.observe("start", info, Observer.OnStart)
 
 	type result struct {
 		err error
@@ -129,7 +252,7 @@ func (q *Queue) execute(task *task) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				done <- result{err: fmt.Errorf("panic: %v", r)}
+				done <- result{err: &PanicError{Value: r, Stack: debug.Stack()}}
 				return
 			}
 		}()
@@ -142,58 +265,108 @@ func (q *Queue) execute(task *task) {
 		err = r.err
 	case <-ctx.Done():
 		if ctx.Err() == context.DeadlineExceeded {
-			err = fmt.Errorf("task timeout after %s", task.timeout)
+			err = &TimeoutError{Timeout: timeout}
 
-			slog.Debug("task.timeout_triggered",
+			q.logger.log(slog.LevelDebug, "task.timeout_triggered",
 				"id", task.ID,
 				"preset", task.preset,
-				"timeout", task.timeout)
+				"timeout", timeout,
+				task.labelAttr())
 		} else {
 			err = ctx.Err()
 		}
 	}
 
 	elapsed := time.Since(start)
+	q.pending.Done(task)
+	if err != nil {
+		task.failures = append(task.failures, err.Error())
+	}
+
+	info.Elapsed = elapsed
+	info.Err = err
+	var panicErr *PanicError
+	var timeoutErr *TimeoutError
+	switch {
+	case errors.As(err, &panicErr):
+		q.observe("panic", info, Observer.OnPanic)
+	case errors.As(err, &timeoutErr):
+		q.observe("timeout", info, Observer.OnTimeout)
+	}
+
+	if err != nil && task.isCanceled() {
+		q.journal.write(journalCancel, task)
+		q.finalize(task, StateCanceled, err, elapsed)
+		q.logger.log(slog.LevelInfo, "task.canceled",
+			"id", task.ID,
+			"preset", task.preset,
+			"running", true,
+			"elapsed_ms", elapsed.Milliseconds(),
+			task.labelAttr(),
+		)
+		return
+	}
 
 	if err != nil {
-		if task.retryOn && task.retryTimes < task.retryMax {
-			if retryErr := q.setRetry(task, err, elapsed); retryErr != nil {
-				slog.Error("task.retry_failed",
+		if task.retryOn && task.retryTimes < task.retryMax && task.shouldRetry(err) {
+			retryErr := q.setRetry(task, err, elapsed)
+			if errors.Is(retryErr, ErrQueueClosed) {
+				q.cancelDelayed(task)
+				return
+			}
+			if retryErr != nil {
+				q.logger.log(slog.LevelError, "task.retry_failed",
 					"id", task.ID,
 					"preset", task.preset,
 					"retry_times", task.retryTimes,
 					"retry_max", task.retryMax,
 					"error", err,
 					"retry_error", retryErr,
+					task.labelAttr(),
 				)
+				q.journal.write(journalFail, task)
+				q.finalize(task, StateFailed, err, elapsed)
+				q.observe("failure", info, Observer.OnFailure)
 			}
 			return
 		}
 
+		q.journal.write(journalFail, task)
+
 		if task.retryOn && task.retryTimes >= task.retryMax {
-			slog.Error("task.exhausted",
+			q.finalize(task, StateExhausted, err, elapsed)
+			q.observe("exhausted", info, Observer.OnExhausted)
+			q.logger.log(slog.LevelError, "task.exhausted",
 				"id", task.ID,
 				"preset", task.preset,
 				"retry_times", task.retryTimes,
 				"retry_max", task.retryMax,
 				"error", err,
 				"elapsed_ms", elapsed.Milliseconds(),
+				task.labelAttr(),
 			)
 		} else {
-			slog.Error("task.failed",
+			q.finalize(task, StateFailed, err, elapsed)
+			q.observe("failure", info, Observer.OnFailure)
+			q.logger.log(slog.LevelError, "task.failed",
 				"id", task.ID,
 				"preset", task.preset,
 				"error", err,
 				"elapsed_ms", elapsed.Milliseconds(),
+				task.labelAttr(),
 			)
 		}
 	} else {
+		q.journal.write(journalComplete, task)
+		q.finalize(task, StateSucceeded, nil, elapsed)
+		q.observe("success", info, Observer.OnSuccess)
 
-		slog.Info("task.completed",
+		q.logger.log(slog.LevelInfo, "task.completed",
 			"id", task.ID,
 			"preset", task.preset,
 			"retry_times", task.retryTimes,
 			"elapsed_ms", elapsed.Milliseconds(),
+			task.labelAttr(),
 		)
 
 		// * Callback after task completion
@@ -203,20 +376,51 @@ func (q *Queue) execute(task *task) {
 	}
 }
 
+func (q *Queue) finalize(task *task, state TaskState, err error, elapsed time.Duration) {
+	task.release()
+	q.status.finish(task.ID, state, err, elapsed)
+	q.pending.Forget(task)
+	if state == StateSucceeded && task.uniqueFor > 0 {
+		q.status.lockUnique(task.ID, task.uniqueFor)
+	}
+	if state == StateFailed || state == StateExhausted {
+		q.deadLetter(task, state)
+	}
+	if task.onFinish != nil {
+		task.onFinish(state, err)
+	}
+}
+
 func (q *Queue) setRetry(task *task, err error, elapsed time.Duration) error {
-	slog.Warn("task.retrying",
+	q.logger.log(slog.LevelWarn, "task.retrying",
 		"id", task.ID,
 		"preset", task.preset,
 		"retry_times", task.retryTimes,
 		"retry_max", task.retryMax,
 		"error", err,
 		"elapsed_ms", elapsed.Milliseconds(),
+		task.labelAttr(),
 	)
 
 	task.retryTimes++
 	task.priority = PriorityRetry
 	task.startAt = time.Now()
+	task.runAt = time.Time{}
+
+	// * 退避期間留在延遲區，不佔用 Policy
+	if task.backoff != nil {
+		task.retryDelay = task.backoff.Next(task.retryTimes, task.retryDelay)
+		task.runAt = task.startAt.Add(task.retryDelay)
+	}
 
+	q.journal.write(journalRetry, task)
+	q.status.retry(task, err, elapsed)
+
+	info := newTaskInfo(task)
+	info.Elapsed = elapsed
+	info.Err = err
+	q.observe("retry", info, Observer.OnRetry)
+	defer q.evict()
 	return q.pending.Push(task)
 }
 
@@ -227,8 +431,13 @@ func (q *Queue) Enqueue(ctx context.Context, presetName string, action func(ctx
 	default:
 	}
 
+	return q.push(ctx, q.newTask(presetName, action, options))
+}
+
+func (q *Queue) newTask(presetName string, action func(ctx context.Context) error, options []EnqueueOption) *task {
 	config := &enqueueConfig{
 		timeout: q.config.getQueueTimeout(presetName),
+		backoff: q.config.Preset[presetName].Backoff,
 	}
 	for _, option := range options {
 		option(config)
@@ -246,38 +455,97 @@ func (q *Queue) Enqueue(ctx context.Context, presetName string, action func(ctx
 		}
 	}
 
-	task := &task{
-		ID:       config.taskID,
-		preset:   presetName,
-		priority: q.config.Preset[presetName].Priority,
-		action:   action,
-		timeout:  config.timeout,
-		callback: config.callback,
-		startAt:  time.Now(),
-		retryOn:  config.retryOn,
-		retryMax: retryMax,
+	now := time.Now()
+	return &task{
+		ID:          config.taskID,
+		preset:      presetName,
+		priority:    q.config.Preset[presetName].Priority,
+		action:      action,
+		timeout:     config.timeout,
+		callback:    config.callback,
+		retryIf:     config.retryIf,
+		onFinish:    config.onFinish,
+		enqueueAt:   now,
+		startAt:     now,
+		runAt:       config.runAt,
+		retryOn:     config.retryOn,
+		retryMax:    retryMax,
+		backoff:     config.backoff,
+		orderingKey: config.orderingKey,
+		uniqueFor:   config.uniqueFor,
+		dedupReject: config.dedupReject,
+		debounceKey: config.debounceKey,
+		coalesceKey: config.coalesceKey,
+		block:       config.block,
+		labels:      config.labels,
+		bound:       config.bound,
+		deadline:    config.deadline,
 	}
+}
 
-	err := q.pending.Push(task)
+func (q *Queue) push(ctx context.Context, task *task) (string, error) {
+	defer q.evict()
+
+	// * 僅在需要時保留 enqueue ctx，避免延長其生命週期
+	if q.config.ContextPropagator != nil {
+		task.enqueueCtx = ctx
+	}
+	if task.bound {
+		if deadline, ok := ctx.Deadline(); ok && (task.deadline.IsZero() || deadline.Before(task.deadline)) {
+			task.deadline = deadline
+		}
+	}
+
+	mode := q.dedupMode(task)
+	if !q.status.admit(task, mode != DedupOff) {
+		if mode == DedupReturnExisting {
+			return task.ID, nil
+		}
+		return "", fmt.Errorf("enqueue failed: %w: %s", ErrDuplicateTask, task.ID)
+	}
+
+	if task.debounceKey != "" || task.coalesceKey != "" {
+		return q.pushMerge(ctx, task)
+	}
+
+	// * 先寫入 journal，避免 worker 的 complete 紀錄早於 enqueue
+	q.journal.write(journalEnqueue, task)
+
+	// * 入隊後 worker 可能立即修改任務，事先擷取
+	info := newTaskInfo(task)
+	var err error
+	if task.block {
+		err = q.pending.PushWait(ctx, task)
+	} else {
+		err = q.pending.Push(task)
+	}
 	if err != nil {
+		q.journal.write(journalFail, task)
+		q.status.remove(task.ID)
 		return "", fmt.Errorf("enqueue failed: %w", err)
 	}
 
+	q.bind(ctx, task)
+	q.observe("enqueue", info, Observer.OnEnqueue)
 	return task.ID, nil
 }
 
 func (q *Queue) Shutdown(ctx context.Context) error {
+	var previous queueState
 	for {
-		current := queueState(q.state.Load())
-		if current == stateClosed {
+		previous = queueState(q.state.Load())
+		if previous == stateClosed {
 			return nil
 		}
-		if q.state.CompareAndSwap(uint32(current), uint32(stateClosed)) {
+		if q.state.CompareAndSwap(uint32(previous), uint32(stateClosed)) {
 			break
 		}
 	}
 
-	q.pending.Close()
+	// * 先喚醒阻塞中的入隊，避免排程器等待空位而無法結束
+	canceled := q.pending.Close()
+	q.closeScheduler()
+	q.cancelDelayed(canceled...)
 
 	done := make(chan struct{})
 	go func() {
@@ -290,13 +558,26 @@ func (q *Queue) Shutdown(ctx context.Context) error {
 		if q.cancel != nil {
 			q.cancel()
 		}
+		if err := q.journal.close(); err != nil {
+			q.logger.log(slog.LevelError, "journal.close_failed", "error", err)
+		}
+		if err := q.pending.closeSpill(); err != nil {
+			q.logger.log(slog.LevelError, "overflow.close_failed", "error", err)
+		}
+		q.closeDeadLetter()
 	case <-ctx.Done():
 		if q.cancel != nil {
 			q.cancel()
 		}
-		return fmt.Errorf("shutdown timeout: %d tasks remaining", q.pending.Len())
+		if err := q.journal.close(); err != nil {
+			q.logger.log(slog.LevelError, "journal.close_failed", "error", err)
+		}
+		if err := q.pending.closeSpill(); err != nil {
+			q.logger.log(slog.LevelError, "overflow.close_failed", "error", err)
+		}
+		q.closeDeadLetter()
+		return &ShutdownError{Remaining: q.pending.Len(), Err: ctx.Err()}
 	}
-
 	return nil
 }
 
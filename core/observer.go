@@ -0,0 +1,80 @@
+package core
+
+import (
+	"log/slog"
+	"time"
+)
+
+type Observer interface {
+	OnEnqueue(info TaskInfo)
+	OnStart(info TaskInfo)
+	OnSuccess(info TaskInfo)
+	OnFailure(info TaskInfo)
+	OnRetry(info TaskInfo)
+	OnExhausted(info TaskInfo)
+	OnPromote(info TaskInfo)
+	OnTimeout(info TaskInfo)
+	OnPanic(info TaskInfo)
+}
+
+// * 嵌入後只需實作關心的事件
+type BaseObserver struct{}
+
+func (BaseObserver) OnEnqueue(TaskInfo)   {}
+func (BaseObserver) OnStart(TaskInfo)     {}
+func (BaseObserver) OnSuccess(TaskInfo)   {}
+func (BaseObserver) OnFailure(TaskInfo)   {}
+func (BaseObserver) OnRetry(TaskInfo)     {}
+func (BaseObserver) OnExhausted(TaskInfo) {}
+func (BaseObserver) OnPromote(TaskInfo)   {}
+func (BaseObserver) OnTimeout(TaskInfo)   {}
+func (BaseObserver) OnPanic(TaskInfo)     {}
+
+type TaskInfo struct {
+	ID           string
+	Preset       string
+	Handler      string
+	Labels       map[string]string
+	Priority     Priority
+	PromotedFrom Priority // 僅 OnPromote
+	Attempt      int      // 已開始的執行次數，含目前這次
+	RetryMax     int      // 未啟用重試時為 0
+	EnqueuedAt   time.Time
+	RunAt        time.Time     // 延遲或退避後的執行時間
+	Deadline     time.Time     // 絕對截止時間
+	StartedAt    time.Time     // 最近一次開始執行
+	Elapsed      time.Duration // 最近一次執行耗時
+	Err          error
+}
+
+func newTaskInfo(t *task) TaskInfo {
+	info := TaskInfo{
+		ID:         t.ID,
+		Preset:     t.preset,
+		Handler:    t.handler,
+		Labels:     t.labels,
+		Priority:   t.priority,
+		Attempt:    t.retryTimes,
+		EnqueuedAt: t.enqueueAt,
+		RunAt:      t.runAt,
+		Deadline:   t.deadline,
+	}
+	if t.retryOn {
+		info.RetryMax = t.retryMax
+	}
+	return info
+}
+
+// * observer panic 不影響 worker
+func (q *Queue) observe(event string, info TaskInfo, fn func(o Observer, info TaskInfo)) {
+	for _, o := range q.config.Observers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					q.logger.log(slog.LevelError, "observer.panic", "event", event, "id", info.ID, "panic", r)
+				}
+			}()
+			fn(o, info)
+		}()
+	}
+}
@@ -3,11 +3,25 @@ package core
 import "time"
 
 type enqueueConfig struct {
-	taskID   string
-	timeout  time.Duration
-	callback func(string)
-	retryOn  bool
-	retryMax *int
+	taskID      string
+	timeout     time.Duration
+	callback    func(string)
+	retryOn     bool
+	retryMax    *int
+	runAt       time.Time
+	overlap     OverlapPolicy
+	backoff     BackoffStrategy
+	retryIf     func(err error, attempt int) bool
+	orderingKey string
+	uniqueFor   time.Duration
+	dedupReject bool
+	debounceKey string
+	coalesceKey string
+	block       bool
+	labels      map[string]string
+	bound       bool
+	deadline    time.Time
+	onFinish    func(state TaskState, err error)
 }
 
 type EnqueueOption func(*enqueueConfig)
@@ -40,3 +54,42 @@ func WithRetry(retryMax ...int) EnqueueOption {
 		}
 	}
 }
+
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.runAt = time.Now().Add(d)
+	}
+}
+
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.runAt = t
+	}
+}
+
+// * attempt 為已執行次數；回傳 false 則不再重試
+func WithRetryIf(fn func(err error, attempt int) bool) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.retryIf = fn
+	}
+}
+
+// * 同 key 的任務依入隊順序逐一執行，重試期間不會被後續任務超前
+func WithOrderingKey(key string) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.orderingKey = key
+	}
+}
+
+// * 僅作用於 Schedule
+func WithOverlap(policy OverlapPolicy) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.overlap = policy
+	}
+}
+
+func withFinish(fn func(state TaskState, err error)) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.onFinish = fn
+	}
+}
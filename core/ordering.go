@@ -0,0 +1,88 @@
+package core
+
+// * 同 key 的任務依入隊順序排隊，僅佇列首位且無同 key 執行中時可取出
+type orderingKey struct {
+	tasks   []*task
+	running bool
+}
+
+func (p *pending) registerKeyLocked(t *task) {
+	if t.orderingKey == "" || t.keyed {
+		return
+	}
+	k, ok := p.keys[t.orderingKey]
+	if !ok {
+		k = &orderingKey{}
+		p.keys[t.orderingKey] = k
+	}
+	k.tasks = append(k.tasks, t)
+	t.keyed = true
+}
+
+func (p *pending) keyReadyLocked(t *task) bool {
+	if t.orderingKey == "" {
+		return true
+	}
+	k, ok := p.keys[t.orderingKey]
+	return ok && !k.running && len(k.tasks) > 0 && k.tasks[0] == t
+}
+
+func (p *pending) setKeyRunningLocked(t *task, running bool) {
+	if t.orderingKey == "" {
+		return
+	}
+	if k, ok := p.keys[t.orderingKey]; ok {
+		k.running = running
+	}
+}
+
+func (p *pending) forgetKeyLocked(t *task) {
+	if !t.keyed {
+		return
+	}
+	t.keyed = false
+
+	k, ok := p.keys[t.orderingKey]
+	if !ok {
+		return
+	}
+	for i, v := range k.tasks {
+		if v == t {
+			k.tasks = append(k.tasks[:i], k.tasks[i+1:]...)
+			break
+		}
+	}
+	if len(k.tasks) == 0 && !k.running {
+		delete(p.keys, t.orderingKey)
+	}
+}
+
+// * 取代等待中的任務時沿用其在 key 佇列中的位置
+func (p *pending) replaceKeyLocked(old, t *task) {
+	if !old.keyed || old.orderingKey != t.orderingKey {
+		p.forgetKeyLocked(old)
+		return
+	}
+	k := p.keys[old.orderingKey]
+	for i, v := range k.tasks {
+		if v == old {
+			k.tasks[i] = t
+			break
+		}
+	}
+	old.keyed = false
+	t.keyed = true
+}
+
+// * 任務進入終止狀態後釋放 key，重試期間仍占住佇列首位
+func (p *pending) Forget(t *task) {
+	if t.orderingKey == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.forgetKeyLocked(t)
+	p.cond.Broadcast()
+}
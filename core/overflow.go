@@ -0,0 +1,256 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+type OverflowPolicy int
+
+const (
+	OverflowReject      OverflowPolicy = iota // 回傳 ErrQueueFull
+	OverflowEvictLowest                       // 移除優先級最低中最舊的任務
+	OverflowEvictLower                        // 新任務優先級較高時才移除
+	OverflowSpill                             // 具名任務寫入溢出檔，有空位時再載回
+)
+
+type EvictedTask struct {
+	ID         string
+	Preset     string
+	Priority   Priority
+	Handler    string
+	Payload    []byte
+	EnqueuedAt time.Time
+	Action     func(ctx context.Context) error
+}
+
+type spillSpan struct {
+	offset int64
+	size   int
+	task   *task
+}
+
+// * 僅作為暫存，重啟後由 journal 還原，開啟時清空
+// * 任務本體留在記憶體以保留 callback 與選項，檔案僅保存 payload
+type spillFile struct {
+	file   *os.File
+	spans  []spillSpan
+	head   int
+	live   int
+	limit  int
+	end    int64
+	logger *logger
+}
+
+func openSpill(path string, limit int, logger *logger) (*spillFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{file: file, limit: limit, logger: logger}, nil
+}
+
+func (s *spillFile) len() int {
+	if s == nil {
+		return 0
+	}
+	return s.live
+}
+
+func (s *spillFile) push(t *task) error {
+	line, err := json.Marshal(newJournalRecord(journalEnqueue, t))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := s.file.WriteAt(line, s.end); err != nil {
+		return err
+	}
+	s.spans = append(s.spans, spillSpan{offset: s.end, size: len(line), task: t})
+	s.end += int64(len(line))
+	s.live++
+
+	t.payload = nil
+	t.action = nil
+	return nil
+}
+
+func (s *spillFile) pop() (*task, journalRecord, error) {
+	for s.spans[s.head].task == nil {
+		s.head++
+	}
+	span := s.spans[s.head]
+	s.head++
+	s.live--
+	// * 全部載回後截斷檔案，回收空間
+	if s.live == 0 {
+		defer s.reset()
+	}
+
+	var r journalRecord
+	buf := make([]byte, span.size)
+	if _, err := s.file.ReadAt(buf, span.offset); err != nil {
+		return span.task, r, err
+	}
+	err := json.Unmarshal(buf, &r)
+	return span.task, r, err
+}
+
+func (s *spillFile) remove(id string) *task {
+	if s == nil {
+		return nil
+	}
+	for i := s.head; i < len(s.spans); i++ {
+		if t := s.spans[i].task; t != nil && t.ID == id && !t.held {
+			s.spans[i].task = nil
+			s.live--
+			if s.live == 0 {
+				s.reset()
+			}
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *spillFile) reset() {
+	s.spans = nil
+	s.head = 0
+	s.live = 0
+	s.end = 0
+	if err := s.file.Truncate(0); err != nil {
+		s.logger.log(slog.LevelError, "overflow.truncate_failed", "error", err)
+	}
+}
+
+func (s *spillFile) close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func (p *pending) overflowLocked(t *task) error {
+	switch p.overflow {
+	case OverflowEvictLowest, OverflowEvictLower:
+		victim := p.victimLocked()
+		if victim == nil || (p.overflow == OverflowEvictLower && t.priority >= victim.priority) {
+			return ErrQueueFull
+		}
+		p.removeTaskLocked(victim)
+		p.evicted = append(p.evicted, victim)
+		p.insertLocked(t)
+		return nil
+
+	case OverflowSpill:
+		// * closure 無法序列化，僅具名任務可寫入溢出檔
+		// * 任務本體仍佔用記憶體，超過上限時拒絕
+		if p.spill == nil || t.handler == "" || p.spill.len() >= p.spill.limit {
+			return ErrQueueFull
+		}
+		// * 占住 key 首位的重試留在記憶體，避免同 key 任務塞滿佇列後無法載回
+		if t.keyed {
+			p.insertLocked(t)
+			return nil
+		}
+		if err := p.spill.push(t); err != nil {
+			return err
+		}
+		p.registerKeyLocked(t)
+		return nil
+	}
+	return ErrQueueFull
+}
+
+func (p *pending) victimLocked() *task {
+	var victim *task
+	check := func(t *task) {
+		if t.held {
+			return
+		}
+		if victim == nil ||
+			t.priority > victim.priority ||
+			(t.priority == victim.priority && t.enqueueAt.Before(victim.enqueueAt)) {
+			victim = t
+		}
+	}
+	for t := range p.queued {
+		check(t)
+	}
+	for _, t := range p.delayed {
+		check(t)
+	}
+	return victim
+}
+
+func (p *pending) refillLocked() {
+	for p.spill.len() > 0 && p.policy.Len()+p.delayed.Len() < p.size {
+		t, r, err := p.spill.pop()
+		if err == nil && !p.rebind(t, r.Payload) {
+			err = fmt.Errorf("handler %q not found", t.handler)
+		}
+		// * 無法載回的任務視為被移除，避免停留在 key 佇列
+		if err != nil {
+			p.logger.log(slog.LevelError, "overflow.read_failed", "id", t.ID, "error", err)
+			p.forgetKeyLocked(t)
+			p.evicted = append(p.evicted, t)
+			continue
+		}
+		p.insertLocked(t)
+	}
+}
+
+func (p *pending) Evicted() []*task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evicted := p.evicted
+	p.evicted = nil
+	return evicted
+}
+
+func (p *pending) closeSpill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.spill.close()
+}
+
+func (q *Queue) rebindTask(t *task, payload []byte) bool {
+	handler, ok := q.handlers.get(t.handler)
+	if !ok {
+		return false
+	}
+	t.payload = payload
+	t.action = bindHandler(handler, payload)
+	return true
+}
+
+func (q *Queue) evict() {
+	for _, task := range q.pending.Evicted() {
+		q.logger.log(slog.LevelWarn, "task.evicted",
+			"id", task.ID,
+			"preset", task.preset,
+			"priority", task.priority,
+			task.labelAttr(),
+		)
+		q.journal.write(journalCancel, task)
+		q.finalize(task, StateEvicted, ErrEvicted, 0)
+
+		if q.config.OnEvict != nil {
+			q.config.OnEvict(EvictedTask{
+				ID:         task.ID,
+				Preset:     task.preset,
+				Priority:   task.priority,
+				Handler:    task.handler,
+				Payload:    task.payload,
+				EnqueuedAt: task.enqueueAt,
+				Action:     task.action,
+			})
+		}
+	}
+}
@@ -2,7 +2,6 @@ package core
 
 import (
 	"container/heap"
-	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,51 +10,62 @@ import (
 type pending struct {
 	mu        sync.Mutex
 	cond      *sync.Cond
-	heap      *taskHeap
+	space     *sync.Cond
+	waiters   []*spaceWaiter
+	waitSeq   uint64
+	overflow  OverflowPolicy
+	evicted   []*task
+	spill     *spillFile
+	rebind    func(t *task, payload []byte) bool
+	logger    *logger
+	policy    Policy
+	queued    map[*task]*QueuedTask
+	seq       uint64
+	delayed   delayHeap
+	timer     *time.Timer
+	running   map[string]*task
+	active    map[string]int
+	busy      int
+	workers   int
+	presets   map[string]PresetConfig
+	limiters  map[string]*tokenBucket
+	keys      map[string]*orderingKey
+	debounced map[string]*task
+	coalesced map[string]*task
+	wake      *time.Timer
+	wakeAt    time.Time
 	size      int
+	deadlines int
 	state     *atomic.Uint32
-	promotion map[Priority]promotion
-}
-
-type promotion struct {
-	After time.Duration
-	To    Priority
 }
 
 type promotionTask struct {
 	taskID string
 	from   Priority
 	to     Priority
+	info   TaskInfo
 }
 
-func (c *Config) getPromotion() map[Priority]promotion {
-	timeout := c.Timeout
-	return map[Priority]promotion{
-		PriorityLow: {
-			After: min(max(timeout, 30*time.Second), 120*time.Second),
-			To:    PriorityNormal,
-		},
-		PriorityNormal: {
-			After: min(max(timeout*2, 30*time.Second), 120*time.Second),
-			To:    PriorityHigh,
-		},
-	}
-}
-
-func newPending(workers, size int, promotion map[Priority]promotion, queueState *atomic.Uint32) *pending {
-	minCap := max(16, min(size/8, size/workers))
-	h := &taskHeap{
-		minCap: minCap,
-	}
-	heap.Init(h)
-
+func newPending(workers, size int, policy Policy, presets map[string]PresetConfig, queueState *atomic.Uint32) *pending {
 	newPending := &pending{
-		heap:      h,
+		policy:    policy,
+		queued:    make(map[*task]*QueuedTask),
+		running:   make(map[string]*task),
+		active:    make(map[string]int),
+		workers:   workers,
+		presets:   presets,
+		limiters:  make(map[string]*tokenBucket),
+		keys:      make(map[string]*orderingKey),
+		debounced: make(map[string]*task),
+		coalesced: make(map[string]*task),
 		size:      size,
-		promotion: promotion,
 		state:     queueState,
 	}
 	newPending.cond = sync.NewCond(&newPending.mu)
+	newPending.space = sync.NewCond(&newPending.mu)
+	for name, config := range presets {
+		newPending.setRateLimitLocked(name, config.RateLimit)
+	}
 	return newPending
 }
 
@@ -63,69 +73,188 @@ func (p *pending) Push(t *task) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	return p.pushLocked(t)
+}
+
+func (p *pending) pushLocked(t *task) error {
 	current := queueState(p.state.Load())
 	if current == stateClosed {
-		return fmt.Errorf("staging queue is closed")
+		// * 關閉期間僅接受立即重試；需退避的重試交由 journal 重播
+		if t.retryTimes == 0 || t.runAt.After(time.Now()) {
+			return ErrQueueClosed
+		}
 	}
 
-	if p.heap.Len() >= p.size {
-		return fmt.Errorf("staging queue is full")
+	// * 先載回溢出的任務，維持入隊順序
+	p.refillLocked()
+	if p.policy.Len()+p.delayed.Len() >= p.size {
+		return p.overflowLocked(t)
+	}
+	// * 空位優先留給同等或更高優先級的等待者
+	if len(p.waiters) > 0 && p.waiters[0].priority <= t.priority {
+		return ErrQueueFull
 	}
 
-	heap.Push(p.heap, t)
-	p.cond.Signal()
+	p.insertLocked(t)
 	return nil
 }
 
-func (p *pending) Pop() (*task, []promotionTask, bool) {
+func (p *pending) insertLocked(t *task) {
+	p.registerKeyLocked(t)
+	p.registerMergeLocked(t)
+	p.trackDeadlineLocked(t, 1)
+
+	if t.runAt.After(time.Now()) {
+		heap.Push(&p.delayed, t)
+		p.scheduleLocked()
+		return
+	}
+
+	p.enterLocked(t)
+	p.cond.Signal()
+}
+
+func (p *pending) enterLocked(t *task) {
+	p.seq++
+	item := newQueuedTask(t, p.seq)
+	p.queued[t] = item
+	p.policy.Push(item)
+}
+
+func (p *pending) leaveLocked(t *task) {
+	if item, ok := p.queued[t]; ok {
+		p.policy.Remove(item)
+		delete(p.queued, t)
+	}
+}
+
+// * 過期任務一併回傳，由 worker 在鎖外結束
+func (p *pending) Pop() (*task, []promotionTask, []*task, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	var events []promotionTask
+	var expired []*task
 	for {
+		p.refillLocked()
 		state := queueState(p.state.Load())
-		if state == stateClosed && p.heap.Len() == 0 {
-			return nil, nil, false
+		if state == stateClosed && p.policy.Len() == 0 {
+			return nil, events, expired, false
 		}
 
-		events := p.promoteLocked()
+		now := time.Now()
+		p.dueLocked(now)
+		expired = append(expired, p.expireLocked(now)...)
+		events = append(events, p.tickLocked(now)...)
 
-		if p.heap.Len() > 0 {
-			task := heap.Pop(p.heap).(*task)
-			return task, events, true
+		// * 受限 preset 的任務留在 Policy，等待 Done 或 token 補充喚醒
+		if task := p.takeLocked(); task != nil {
+			p.acquireLocked(task)
+			p.refillLocked()
+			p.space.Broadcast()
+			return task, events, expired, true
 		}
-
-		if state == stateClosed {
-			return nil, nil, false
+		if len(expired) > 0 {
+			return nil, events, expired, true
+		}
+		if p.policy.Len() > 0 {
+			p.armRefillLocked(now)
 		}
 
 		p.cond.Wait()
 	}
 }
 
+func (p *pending) Done(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.releaseLocked(t)
+	p.cond.Broadcast()
+}
+
+func (p *pending) Cancel(id string) (CancelResult, *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t := p.removeLocked(id); t != nil {
+		p.refillLocked()
+		p.cond.Broadcast()
+		p.space.Broadcast()
+		return CancelPending, t
+	}
+
+	if t := p.spill.remove(id); t != nil {
+		p.forgetKeyLocked(t)
+		p.cond.Broadcast()
+		return CancelPending, t
+	}
+
+	if t, ok := p.running[id]; ok {
+		return CancelRunning, t
+	}
+	return CancelNotFound, nil
+}
+
+func (p *pending) removeLocked(id string) *task {
+	for t := range p.queued {
+		if t.ID == id && !t.held {
+			p.removeTaskLocked(t)
+			return t
+		}
+	}
+	for _, t := range p.delayed {
+		if t.ID == id && !t.held {
+			p.removeTaskLocked(t)
+			return t
+		}
+	}
+	return nil
+}
+
+// * 依指標移除，DedupOff 時可能有多個任務共用同一 ID
+func (p *pending) removeTaskLocked(t *task) {
+	if _, ok := p.queued[t]; ok {
+		p.leaveLocked(t)
+	} else {
+		for i, v := range p.delayed {
+			if v == t {
+				heap.Remove(&p.delayed, i)
+				p.scheduleLocked()
+				break
+			}
+		}
+	}
+	p.forgetKeyLocked(t)
+	p.forgetMergeLocked(t)
+	p.trackDeadlineLocked(t, -1)
+}
+
 func (p *pending) Len() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return p.heap.Len()
+	return p.policy.Len() + p.delayed.Len() + p.spill.len()
 }
 
-func (p *pending) promoteLocked() []promotionTask {
+// * Policy 調整的 Priority 同步回任務
+func (p *pending) tickLocked(now time.Time) []promotionTask {
 	var events []promotionTask
-	now := time.Now()
-
-	tasks := p.heap.tasks
-	for i := p.heap.Len() - 1; i >= 0; i-- {
-		t := tasks[i]
-		rule, ok := p.promotion[t.priority]
-		if !ok || now.Sub(t.startAt) < rule.After || rule.To >= t.priority {
+	for _, item := range p.policy.OnTick(now) {
+		t := item.task
+		if t == nil || p.queued[t] != item || item.Priority == t.priority {
 			continue
 		}
+		from := t.priority
+		t.priority = item.Priority
+
+		info := newTaskInfo(t)
+		info.PromotedFrom = from
 		events = append(events, promotionTask{
 			taskID: t.ID,
-			from:   t.priority,
-			to:     rule.To,
+			from:   from,
+			to:     t.priority,
+			info:   info,
 		})
-		t.priority = rule.To
-		heap.Fix(p.heap, i)
 	}
 	return events
 }
@@ -134,8 +263,23 @@ func (p *pending) State() queueState {
 	return queueState(p.state.Load())
 }
 
-func (p *pending) Close() {
+// * 退避中的重試立即執行；其餘未到期的延遲任務不再等待，回傳後由 Queue 以 canceled 結束
+func (p *pending) Close() []*task {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	var canceled []*task
+	for p.delayed.Len() > 0 {
+		t := heap.Pop(&p.delayed).(*task)
+		p.forgetKeyLocked(t)
+		p.forgetMergeLocked(t)
+		p.trackDeadlineLocked(t, -1)
+		canceled = append(canceled, t)
+	}
 	p.cond.Broadcast()
+	p.space.Broadcast()
+	return canceled
 }
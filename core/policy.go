@@ -0,0 +1,312 @@
+package core
+
+import (
+	"container/heap"
+	"time"
+)
+
+const (
+	policyHeapMinCap      = 16
+	policyHeapShrinkRatio = 8
+)
+
+// * 所有方法皆在 pending 鎖內呼叫，實作不需自行加鎖；每個 Queue 需使用獨立實例
+// * 受限（bulkhead、限流、ordering key）而暫不可執行的任務會被 Pop 後再 Push 放回，
+// * 排序應依 QueuedTask 欄位決定，放回後才會回到原位置
+type Policy interface {
+	Push(t *QueuedTask)
+	Pop() *QueuedTask
+	Remove(t *QueuedTask)
+	Len() int
+	// 回傳 Priority 被調整的任務，由佇列同步狀態並觸發 OnPromote
+	OnTick(now time.Time) []*QueuedTask
+}
+
+type QueuedTask struct {
+	ID       string
+	Preset   string
+	Handler  string
+	Labels   map[string]string
+	Priority Priority
+	Seq      uint64    // 進入 Policy 的順序，放回時不變；重試與延遲到期會重新編號
+	ReadyAt  time.Time // 可執行的時間：加入、重試或延遲到期
+	Deadline time.Time
+	Timeout  time.Duration
+	Attempt  int
+
+	task   *task
+	index  int
+	rank   float64
+	ranked bool
+}
+
+func newQueuedTask(t *task, seq uint64) *QueuedTask {
+	return &QueuedTask{
+		ID:       t.ID,
+		Preset:   t.preset,
+		Handler:  t.handler,
+		Labels:   t.labels,
+		Priority: t.priority,
+		Seq:      seq,
+		ReadyAt:  t.startAt,
+		Deadline: t.deadline,
+		Timeout:  t.timeout,
+		Attempt:  t.retryTimes,
+		task:     t,
+		index:    -1,
+	}
+}
+
+type policyHeap struct {
+	tasks []*QueuedTask
+	less  func(a, b *QueuedTask) bool
+}
+
+func (h *policyHeap) Len() int {
+	return len(h.tasks)
+}
+
+func (h *policyHeap) Less(i, j int) bool {
+	return h.less(h.tasks[i], h.tasks[j])
+}
+
+func (h *policyHeap) Swap(i, j int) {
+	h.tasks[i], h.tasks[j] = h.tasks[j], h.tasks[i]
+	h.tasks[i].index = i
+	h.tasks[j].index = j
+}
+
+func (h *policyHeap) Push(x interface{}) {
+	t := x.(*QueuedTask)
+	t.index = len(h.tasks)
+	h.tasks = append(h.tasks, t)
+}
+
+func (h *policyHeap) Pop() interface{} {
+	old := h.tasks
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	h.tasks = old[0 : n-1]
+	task.index = -1
+
+	length := len(h.tasks)
+	capacity := cap(h.tasks)
+	if capacity > policyHeapMinCap*4 && length < capacity/policyHeapShrinkRatio {
+		newCap := max(capacity/4, policyHeapMinCap)
+		shrunk := make([]*QueuedTask, length, newCap)
+		copy(shrunk, h.tasks)
+		h.tasks = shrunk
+	}
+
+	return task
+}
+
+type heapPolicy struct {
+	heap policyHeap
+}
+
+func newHeapPolicy(less func(a, b *QueuedTask) bool) *heapPolicy {
+	return &heapPolicy{heap: policyHeap{less: less}}
+}
+
+func (p *heapPolicy) Push(t *QueuedTask) {
+	heap.Push(&p.heap, t)
+}
+
+func (p *heapPolicy) Pop() *QueuedTask {
+	if p.heap.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(&p.heap).(*QueuedTask)
+}
+
+func (p *heapPolicy) Remove(t *QueuedTask) {
+	if t.index < 0 || t.index >= p.heap.Len() || p.heap.tasks[t.index] != t {
+		return
+	}
+	heap.Remove(&p.heap, t.index)
+}
+
+func (p *heapPolicy) Len() int {
+	return p.heap.Len()
+}
+
+func (p *heapPolicy) OnTick(time.Time) []*QueuedTask {
+	return nil
+}
+
+func lessPriority(a, b *QueuedTask) bool {
+	switch {
+	case a.Priority != b.Priority:
+		return a.Priority < b.Priority
+	case !a.ReadyAt.Equal(b.ReadyAt):
+		return a.ReadyAt.Before(b.ReadyAt)
+	}
+	return a.Seq < b.Seq
+}
+
+// * 同 rank 時依 Priority 與先後排序
+func lessRank(a, b *QueuedTask) bool {
+	if a.rank != b.rank {
+		return a.rank < b.rank
+	}
+	return lessPriority(a, b)
+}
+
+func FIFOPolicy() Policy {
+	return newHeapPolicy(func(a, b *QueuedTask) bool {
+		return a.Seq < b.Seq
+	})
+}
+
+func LIFOPolicy() Policy {
+	return newHeapPolicy(func(a, b *QueuedTask) bool {
+		return a.Seq > b.Seq
+	})
+}
+
+// * 不晉升，低優先度任務在高優先度持續湧入時可能無限等待
+func PriorityPolicy() Policy {
+	return newHeapPolicy(lessPriority)
+}
+
+type AgingRule struct {
+	From  Priority
+	To    Priority
+	After time.Duration // 自 ReadyAt 起算
+}
+
+type agingPolicy struct {
+	*heapPolicy
+	rules map[Priority]AgingRule
+}
+
+// * 未帶 rules 時等同 PriorityPolicy；Config.Policy 為 nil 時依 Config.Timeout 產生規則
+func AgingPolicy(rules ...AgingRule) Policy {
+	return newAgingPolicy(lessPriority, rules)
+}
+
+func newAgingPolicy(less func(a, b *QueuedTask) bool, rules []AgingRule) *agingPolicy {
+	p := &agingPolicy{
+		heapPolicy: newHeapPolicy(less),
+		rules:      make(map[Priority]AgingRule, len(rules)),
+	}
+	for _, rule := range rules {
+		p.rules[rule.From] = rule
+	}
+	return p
+}
+
+func (p *agingPolicy) OnTick(now time.Time) []*QueuedTask {
+	var promoted []*QueuedTask
+
+	tasks := p.heap.tasks
+	for i := p.heap.Len() - 1; i >= 0; i-- {
+		t := tasks[i]
+		rule, ok := p.rules[t.Priority]
+		if !ok || now.Sub(t.ReadyAt) < rule.After || rule.To >= t.Priority {
+			continue
+		}
+		t.Priority = rule.To
+		heap.Fix(&p.heap, i)
+		promoted = append(promoted, t)
+	}
+	return promoted
+}
+
+// * 指定 Policy 時 Scheduler 不再生效
+func (c *Config) getPolicy() Policy {
+	if c.Policy != nil {
+		return c.Policy
+	}
+	less := lessPriority
+	if c.Scheduler == SchedulerEDF {
+		less = lessEDF
+	}
+	return newAgingPolicy(less, c.getAgingRules())
+}
+
+func (c *Config) getAgingRules() []AgingRule {
+	timeout := c.Timeout
+	return []AgingRule{
+		{
+			From:  PriorityLow,
+			To:    PriorityNormal,
+			After: min(max(timeout, 30*time.Second), 120*time.Second),
+		},
+		{
+			From:  PriorityNormal,
+			To:    PriorityHigh,
+			After: min(max(timeout*2, 30*time.Second), 120*time.Second),
+		},
+	}
+}
+
+type weightedFairPolicy struct {
+	*heapPolicy
+	weights map[string]int
+	finish  map[string]float64
+	vtime   float64
+}
+
+// * 依 preset 權重分配執行機會，未列出的 preset 權重為 1；同一 preset 內依加入順序
+func WeightedFairPolicy(weights map[string]int) Policy {
+	p := &weightedFairPolicy{
+		heapPolicy: newHeapPolicy(lessRank),
+		weights:    make(map[string]int, len(weights)),
+		finish:     make(map[string]float64),
+	}
+	for name, weight := range weights {
+		p.weights[name] = weight
+	}
+	return p
+}
+
+func (p *weightedFairPolicy) Push(t *QueuedTask) {
+	// * 放回的任務保留原本的完成標記
+	if !t.ranked {
+		weight := p.weights[t.Preset]
+		if weight <= 0 {
+			weight = 1
+		}
+		t.rank = max(p.vtime, p.finish[t.Preset]) + 1/float64(weight)
+		t.ranked = true
+		p.finish[t.Preset] = t.rank
+	}
+	p.heapPolicy.Push(t)
+}
+
+func (p *weightedFairPolicy) Pop() *QueuedTask {
+	t := p.heapPolicy.Pop()
+	if t != nil {
+		p.vtime = max(p.vtime, t.rank)
+	}
+	return t
+}
+
+type shortestJobPolicy struct {
+	*heapPolicy
+	estimate func(t *QueuedTask) time.Duration
+}
+
+// * estimate 為 nil 時以任務的 Timeout 估計；長任務在短任務持續湧入時可能無限等待
+func ShortestJobPolicy(estimate func(t *QueuedTask) time.Duration) Policy {
+	if estimate == nil {
+		estimate = func(t *QueuedTask) time.Duration {
+			return t.Timeout
+		}
+	}
+	return &shortestJobPolicy{
+		heapPolicy: newHeapPolicy(lessRank),
+		estimate:   estimate,
+	}
+}
+
+func (p *shortestJobPolicy) Push(t *QueuedTask) {
+	if !t.ranked {
+		t.rank = float64(p.estimate(t))
+		t.ranked = true
+	}
+	p.heapPolicy.Push(t)
+}
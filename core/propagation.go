@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+)
+
+// * 回傳的 context 須衍生自 exec，才能保留佇列的取消與逾時
+type ContextPropagator interface {
+	Propagate(exec, enqueue context.Context) context.Context
+}
+
+type ContextPropagatorFunc func(exec, enqueue context.Context) context.Context
+
+func (f ContextPropagatorFunc) Propagate(exec, enqueue context.Context) context.Context {
+	return f(exec, enqueue)
+}
+
+// * 沿用整條 enqueue value 鏈，取消仍來自 exec
+func PropagateAll() ContextPropagator {
+	return ContextPropagatorFunc(func(exec, enqueue context.Context) context.Context {
+		return &valuesContext{Context: exec, values: enqueue}
+	})
+}
+
+func PropagateKeys(keys ...any) ContextPropagator {
+	return ContextPropagatorFunc(func(exec, enqueue context.Context) context.Context {
+		for _, key := range keys {
+			if value := enqueue.Value(key); value != nil {
+				exec = context.WithValue(exec, key, value)
+			}
+		}
+		return exec
+	})
+}
+
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *valuesContext) Value(key any) any {
+	if value := c.values.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+func (q *Queue) taskContext(task *task) context.Context {
+	if q.config.ContextPropagator == nil || task.enqueueCtx == nil {
+		return q.ctx
+	}
+	return q.config.ContextPropagator.Propagate(q.ctx, task.enqueueCtx)
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"time"
+)
+
+type RateLimit struct {
+	Rate  float64 // 每秒補充的 token 數，0 = 不限制
+	Burst int     // 最大累積 token 數，0 = 1
+}
+
+type RateLimitState struct {
+	Rate   float64
+	Burst  int
+	Tokens float64
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	burst := max(limit.Burst, 1)
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) ready(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+func (b *tokenBucket) readyAt(now time.Time) time.Time {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return now
+	}
+	return now.Add(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+func (p *pending) setRateLimitLocked(preset string, limit RateLimit) {
+	if limit.Rate <= 0 {
+		delete(p.limiters, preset)
+		return
+	}
+
+	now := time.Now()
+	bucket, ok := p.limiters[preset]
+	if !ok {
+		p.limiters[preset] = newTokenBucket(limit, now)
+		return
+	}
+	// * 保留目前 token，僅調整速率與上限
+	bucket.refill(now)
+	bucket.rate = limit.Rate
+	bucket.burst = max(limit.Burst, 1)
+	bucket.tokens = min(bucket.tokens, float64(bucket.burst))
+}
+
+// * 取得最早可補足 token 的時間，並以 timer 喚醒等待中的 worker
+func (p *pending) armRefillLocked(now time.Time) {
+	var at time.Time
+	for _, bucket := range p.limiters {
+		if ready := bucket.readyAt(now); ready.After(now) && (at.IsZero() || ready.Before(at)) {
+			at = ready
+		}
+	}
+	if at.IsZero() {
+		return
+	}
+	if !p.wakeAt.IsZero() && !at.Before(p.wakeAt) {
+		return
+	}
+
+	p.wakeAt = at
+	if p.wake == nil {
+		p.wake = time.AfterFunc(at.Sub(now), p.onWake)
+		return
+	}
+	p.wake.Reset(at.Sub(now))
+}
+
+func (p *pending) onWake() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wakeAt = time.Time{}
+	p.cond.Broadcast()
+}
+
+func (p *pending) RateLimit(preset string) (RateLimitState, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bucket, ok := p.limiters[preset]
+	if !ok {
+		return RateLimitState{}, false
+	}
+	bucket.refill(time.Now())
+	return RateLimitState{
+		Rate:   bucket.rate,
+		Burst:  bucket.burst,
+		Tokens: bucket.tokens,
+	}, true
+}
+
+func (p *pending) SetRateLimit(preset string, limit RateLimit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.setRateLimitLocked(preset, limit)
+	p.cond.Broadcast()
+}
+
+func (q *Queue) RateLimit(preset string) (RateLimitState, bool) {
+	return q.pending.RateLimit(preset)
+}
+
+// * Rate 為 0 時移除限制
+func (q *Queue) SetRateLimit(preset string, limit RateLimit) {
+	q.pending.SetRateLimit(preset, limit)
+}
@@ -0,0 +1,234 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type OverlapPolicy int
+
+const (
+	OverlapParallel OverlapPolicy = iota // 每次觸發皆入隊
+	OverlapSkip                          // 前一次未結束則略過
+	OverlapQueue                         // 前一次結束後補跑
+)
+
+type scheduler struct {
+	mu      sync.Mutex
+	clock   Clock
+	entries map[string]*scheduleEntry
+	running bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type scheduleEntry struct {
+	id      string
+	spec    cronSpec
+	preset  string
+	action  func(ctx context.Context) error
+	options []EnqueueOption
+	overlap OverlapPolicy
+	stop    chan struct{}
+
+	mu       sync.Mutex
+	inflight int
+	missed   int
+}
+
+func newScheduler(clock Clock) *scheduler {
+	return &scheduler{
+		clock:   clock,
+		entries: make(map[string]*scheduleEntry),
+	}
+}
+
+func (q *Queue) Schedule(spec string, presetName string, action func(ctx context.Context) error, options ...EnqueueOption) (string, error) {
+	parsed, err := parseCron(spec)
+	if err != nil {
+		return "", err
+	}
+
+	config := &enqueueConfig{}
+	for _, option := range options {
+		option(config)
+	}
+
+	id := config.taskID
+	if id == "" {
+		id = generateUUID()
+	}
+
+	// * 每次觸發各自產生 task ID，WithTaskID 僅作為排程 ID
+	taskOptions := make([]EnqueueOption, 0, len(options)+1)
+	taskOptions = append(taskOptions, options...)
+	taskOptions = append(taskOptions, WithTaskID(""))
+
+	entry := &scheduleEntry{
+		id:      id,
+		spec:    parsed,
+		preset:  presetName,
+		action:  action,
+		options: taskOptions,
+		overlap: config.overlap,
+		stop:    make(chan struct{}),
+	}
+
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", fmt.Errorf("scheduler is closed")
+	}
+	if _, ok := s.entries[id]; ok {
+		return "", fmt.Errorf("schedule already exists: %s", id)
+	}
+	s.entries[id] = entry
+
+	if s.running {
+		s.wg.Add(1)
+		go q.runSchedule(entry)
+	}
+	return id, nil
+}
+
+func (q *Queue) Unschedule(id string) bool {
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+	delete(s.entries, id)
+	close(entry.stop)
+	return true
+}
+
+func (q *Queue) startScheduler() {
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = true
+	for _, entry := range s.entries {
+		s.wg.Add(1)
+		go q.runSchedule(entry)
+	}
+}
+
+func (q *Queue) closeScheduler() {
+	s := q.scheduler
+	s.mu.Lock()
+	s.closed = true
+	for id, entry := range s.entries {
+		delete(s.entries, id)
+		close(entry.stop)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (q *Queue) runSchedule(entry *scheduleEntry) {
+	defer q.scheduler.wg.Done()
+
+	clock := q.scheduler.clock
+	now := clock.Now()
+	for {
+		next := entry.spec.Next(now)
+		if next.IsZero() {
+			q.logger.log(slog.LevelWarn, "schedule.exhausted", "id", entry.id)
+			return
+		}
+
+		select {
+		case <-entry.stop:
+			return
+		case <-clock.After(next.Sub(now)):
+		}
+
+		now = clock.Now()
+		if now.Before(next) {
+			now = next
+		}
+		q.tick(entry)
+	}
+}
+
+func (q *Queue) tick(entry *scheduleEntry) {
+	entry.mu.Lock()
+	if entry.inflight > 0 {
+		switch entry.overlap {
+		case OverlapSkip:
+			entry.mu.Unlock()
+			q.logger.log(slog.LevelDebug, "schedule.skipped", "id", entry.id, "preset", entry.preset)
+			return
+		case OverlapQueue:
+			entry.missed++
+			entry.mu.Unlock()
+			return
+		}
+	}
+	entry.inflight++
+	entry.mu.Unlock()
+
+	q.fire(entry)
+}
+
+func (q *Queue) fire(entry *scheduleEntry) {
+	options := append(entry.options[:len(entry.options):len(entry.options)], withFinish(func(TaskState, error) {
+		q.settle(entry)
+	}))
+
+	taskID, err := q.Enqueue(context.Background(), entry.preset, entry.action, options...)
+	if err != nil {
+		q.logger.log(slog.LevelError, "schedule.enqueue_failed", "id", entry.id, "preset", entry.preset, "error", err)
+		q.settle(entry)
+		return
+	}
+	q.logger.log(slog.LevelDebug, "schedule.fired", "id", entry.id, "task_id", taskID, "preset", entry.preset)
+}
+
+func (q *Queue) settle(entry *scheduleEntry) {
+	entry.mu.Lock()
+	entry.inflight--
+	if entry.overlap != OverlapQueue || entry.missed == 0 {
+		entry.mu.Unlock()
+		return
+	}
+	entry.missed--
+	entry.inflight++
+	entry.mu.Unlock()
+
+	select {
+	case <-entry.stop:
+		entry.mu.Lock()
+		entry.inflight--
+		entry.missed = 0
+		entry.mu.Unlock()
+		return
+	default:
+	}
+	q.fire(entry)
+}
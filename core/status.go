@@ -0,0 +1,165 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+type TaskState string
+
+const (
+	StatePending   TaskState = "pending"
+	StateRunning   TaskState = "running"
+	StateRetrying  TaskState = "retrying"
+	StateSucceeded TaskState = "succeeded"
+	StateFailed    TaskState = "failed"
+	StateExhausted TaskState = "exhausted"
+	StateCanceled  TaskState = "canceled"
+	StateEvicted   TaskState = "evicted"
+	StateExpired   TaskState = "expired"
+)
+
+func (s TaskState) Finished() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateExhausted, StateCanceled, StateEvicted, StateExpired:
+		return true
+	}
+	return false
+}
+
+type TaskStatus struct {
+	ID         string
+	State      TaskState
+	Priority   Priority
+	Preset     string
+	Attempts   int
+	LastError  error
+	EnqueuedAt time.Time
+	RunAt      time.Time     // 延遲任務的到期時間
+	Deadline   time.Time     // 絕對截止時間，逾期未執行即 expired
+	StartedAt  time.Time     // 最近一次開始執行
+	FinishedAt time.Time     // 進入終止狀態的時間
+	Elapsed    time.Duration // 最近一次執行耗時
+}
+
+type statusStore struct {
+	mu        sync.Mutex
+	records   map[string]*TaskStatus
+	unique    map[string]time.Time
+	finished  []*TaskStatus
+	next      int
+	retention int
+}
+
+func newStatusStore(retention int) *statusStore {
+	return &statusStore{
+		records:   make(map[string]*TaskStatus),
+		unique:    make(map[string]time.Time),
+		finished:  make([]*TaskStatus, max(retention, 0)),
+		retention: retention,
+	}
+}
+
+func (s *statusStore) enqueue(t *task) {
+	s.admit(t, false)
+}
+
+func (s *statusStore) enqueueLocked(t *task) {
+	state := StatePending
+	if t.retryTimes > 0 {
+		state = StateRetrying
+	}
+	s.records[t.ID] = &TaskStatus{
+		ID:         t.ID,
+		State:      state,
+		Priority:   t.priority,
+		Preset:     t.preset,
+		Attempts:   t.retryTimes,
+		EnqueuedAt: t.startAt,
+		RunAt:      t.runAt,
+		Deadline:   t.deadline,
+	}
+}
+
+func (s *statusStore) update(id string, fn func(r *TaskStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[id]
+	if !ok || r.State.Finished() {
+		return
+	}
+	fn(r)
+
+	if !r.State.Finished() {
+		return
+	}
+	// * 環狀保留已結束的任務，覆寫最舊的一筆
+	if s.retention <= 0 {
+		delete(s.records, id)
+		return
+	}
+	if old := s.finished[s.next]; old != nil && s.records[old.ID] == old {
+		delete(s.records, old.ID)
+	}
+	s.finished[s.next] = r
+	s.next = (s.next + 1) % s.retention
+}
+
+func (s *statusStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[id]; ok && !r.State.Finished() {
+		delete(s.records, id)
+	}
+}
+
+func (s *statusStore) get(id string) (TaskStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[id]
+	if !ok {
+		return TaskStatus{}, false
+	}
+	return *r, true
+}
+
+func (s *statusStore) start(t *task, at time.Time) {
+	s.update(t.ID, func(r *TaskStatus) {
+		r.State = StateRunning
+		r.Priority = t.priority
+		r.Attempts++
+		r.StartedAt = at
+	})
+}
+
+func (s *statusStore) retry(t *task, err error, elapsed time.Duration) {
+	s.update(t.ID, func(r *TaskStatus) {
+		r.State = StateRetrying
+		r.Priority = t.priority
+		r.RunAt = t.runAt
+		r.LastError = err
+		r.Elapsed = elapsed
+	})
+}
+
+func (s *statusStore) promote(id string, to Priority) {
+	s.update(id, func(r *TaskStatus) {
+		r.Priority = to
+	})
+}
+
+func (s *statusStore) finish(id string, state TaskState, err error, elapsed time.Duration) {
+	s.update(id, func(r *TaskStatus) {
+		r.State = state
+		r.LastError = err
+		r.Elapsed = elapsed
+		r.FinishedAt = time.Now()
+	})
+}
+
+func (q *Queue) Status(id string) (TaskStatus, bool) {
+	return q.status.get(id)
+}
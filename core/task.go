@@ -2,67 +2,107 @@ package core
 
 import (
 	"context"
+	"sync"
 	"time"
 )
 
-const (
-	taskHeapMinCapRatio = 8
-)
-
 type task struct {
-	ID         string
-	preset     string
-	priority   Priority
-	action     func(ctx context.Context) error
-	timeout    time.Duration
-	callback   func(id string)
-	startAt    time.Time
-	retryOn    bool
-	retryMax   int
-	retryTimes int
-}
+	ID          string
+	preset      string
+	priority    Priority
+	action      func(ctx context.Context) error
+	handler     string
+	payload     []byte
+	timeout     time.Duration
+	callback    func(id string)
+	onFinish    func(state TaskState, err error)
+	enqueueAt   time.Time
+	startAt     time.Time
+	runAt       time.Time
+	retryOn     bool
+	retryMax    int
+	retryTimes  int
+	backoff     BackoffStrategy
+	retryIf     func(err error, attempt int) bool
+	retryDelay  time.Duration
+	failures    []string
+	orderingKey string
+	keyed       bool
+	uniqueFor   time.Duration
+	dedupReject bool
+	debounceKey string
+	coalesceKey string
+	held        bool
+	block       bool
+	labels      map[string]string
+	enqueueCtx  context.Context
+	bound       bool
+	deadline    time.Time
 
-type taskHeaps []*task
-
-type taskHeap struct {
-	tasks  taskHeaps
-	minCap int
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	canceled bool
+	unbind   func() bool
+	unbound  bool
 }
 
-func (h *taskHeap) Len() int {
-	return len(h.tasks)
+func (t *task) shouldRetry(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if t.retryIf != nil {
+		return t.retryIf(err, t.retryTimes+1)
+	}
+	return true
 }
 
-func (h *taskHeap) Less(i, j int) bool {
-	if h.tasks[i].priority != h.tasks[j].priority {
-		return h.tasks[i].priority < h.tasks[j].priority
+func (t *task) setCancel(cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cancel = cancel
+	// * Cancel 可能早於 execute 建立 context
+	if t.canceled {
+		cancel()
 	}
-	return h.tasks[i].startAt.Before(h.tasks[j].startAt)
 }
 
-func (h *taskHeap) Swap(i, j int) {
-	h.tasks[i], h.tasks[j] = h.tasks[j], h.tasks[i]
+func (t *task) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.canceled = true
+	if t.cancel != nil {
+		t.cancel()
+	}
 }
 
-func (h *taskHeap) Push(x interface{}) {
-	h.tasks = append(h.tasks, x.(*task))
+func (t *task) bind(stop func() bool) {
+	t.mu.Lock()
+	if !t.unbound {
+		t.unbind = stop
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	// * 綁定前已結束
+	stop()
 }
 
-func (h *taskHeap) Pop() interface{} {
-	old := h.tasks
-	n := len(old)
-	task := old[n-1]
-	old[n-1] = nil
-	h.tasks = old[0 : n-1]
+func (t *task) release() {
+	t.mu.Lock()
+	t.unbound = true
+	stop := t.unbind
+	t.unbind = nil
+	t.mu.Unlock()
 
-	length := len(h.tasks)
-	capacity := cap(h.tasks)
-	if capacity > h.minCap*4 && length < capacity/taskHeapMinCapRatio {
-		newCap := max(capacity/4, h.minCap)
-		shrunk := make(taskHeaps, length, newCap)
-		copy(shrunk, h.tasks)
-		h.tasks = shrunk
+	if stop != nil {
+		stop()
 	}
+}
 
-	return task
+func (t *task) isCanceled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.canceled
 }